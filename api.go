@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- HTTP 控制接口 ---
+// 可選的內嵌 HTTP 服務，供 Git 推送鉤子或局域網內的快捷指令觸發運行。
+// 所有請求都需要 Authorization: Bearer 令牌，默認只監聽本機。
+//
+//	GET  /api/profiles              列出配置
+//	GET  /api/runs                  最近的運行
+//	POST /api/runs                  開始運行 {"profile","groups","tasks","force"}
+//	GET  /api/runs/{id}             運行狀態和結果
+//	POST /api/runs/{id}/cancel      取消運行
+//	GET  /api/runs/{id}/events      運行事件（SSE）
+//	POST /api/hooks/{profile}       Webhook，?groups=&tasks= 同上
+
+type APIConfig struct {
+	Enabled bool   `json:"enabled,omitempty"`
+	Addr    string `json:"addr,omitempty"`  // 默認 127.0.0.1:8787
+	Token   string `json:"token,omitempty"` // 可寫作 ${secret:名稱}
+}
+
+const (
+	defaultAPIAddr = "127.0.0.1:8787"
+	apiRunsLimit   = 50
+)
+
+// apiStartFunc 提交一次運行，返回在運行結束時送出結果的通道。
+// r.Started 在真正開始時回調，接口從那時起記錄狀態消息。
+type apiStartFunc func(ctx context.Context, r *runRequest) <-chan runResult
+
+// apiRun 是通過接口發起的一次運行及其開始後收到的事件。
+type apiRun struct {
+	ID      string
+	Profile string
+	Start   time.Time
+	cancel  context.CancelFunc
+	merged  bool // 與排隊中的相同請求合併，取消時只撤回本次請求
+
+	mu     sync.Mutex
+	events []Event
+	result *runResult
+	wake   chan struct{} // 有新事件或運行結束時關閉並換新
+}
+
+// apiRunEventsLimit 是每次運行保留的事件上限，超出後只保留 RunFinished。
+const apiRunEventsLimit = 10000
+
+func (r *apiRun) post(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, last := e.(RunFinished); len(r.events) >= apiRunEventsLimit && !last {
+		return
+	}
+	r.events = append(r.events, e)
+	close(r.wake)
+	r.wake = make(chan struct{})
+}
+
+// record 註冊同步的接收函數，把從現在到下一個 RunFinished 的事件記入本次運行。
+// 隊列同一時間只跑一次運行，開始後的第一個 RunFinished 就是本次的；接收函數不會丟事件，不會錯過它。
+func (r *apiRun) record() {
+	finished := make(chan struct{})
+	done := false // 只在總線的鎖內讀寫
+	stop := events.AddSink(func(e Event) {
+		if done {
+			return
+		}
+		r.post(e)
+		if _, ok := e.(RunFinished); ok {
+			done = true
+			close(finished)
+		}
+	})
+	// 接收函數在總線的鎖內調用，不能在裡面移除自己
+	go func() {
+		<-finished
+		stop()
+	}()
+}
+
+func (r *apiRun) finish(res runResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result = &res
+	close(r.wake)
+	r.wake = make(chan struct{})
+}
+
+// snapshot 返回 from 之後的事件、是否已結束以及下次等待用的通道。
+func (r *apiRun) snapshot(from int) ([]Event, bool, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.events[from:]), r.result != nil, r.wake
+}
+
+type apiRunView struct {
+	ID      string     `json:"id"`
+	Profile string     `json:"profile"`
+	Start   time.Time  `json:"start"`
+	Status  string     `json:"status"`           // running（含排隊中）/ success / failed / cancelled
+	Merged  bool       `json:"merged,omitempty"` // 與排隊中的相同請求合併，取消時只撤回本次請求
+	Report  *runReport `json:"report,omitempty"`
+}
+
+func (r *apiRun) view() apiRunView {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v := apiRunView{ID: r.ID, Profile: r.Profile, Start: r.Start, Status: "running", Merged: r.merged}
+	if r.result != nil {
+		rep := newRunReport(*r.result)
+		v.Status, v.Report = rep.Status, &rep
+	}
+	return v
+}
+
+type apiServer struct {
+	token string
+	load  func(profile string) (Config, error)
+	start apiStartFunc
+
+	mu   sync.Mutex
+	runs []*apiRun
+	seq  int
+}
+
+// serveAPI 按 cfg 啟動 HTTP 服務直到 ctx 取消。
+// load 讀取配置（界面中默認配置取當前編輯中的內容），start 負責真正開始運行。
+func serveAPI(ctx context.Context, cfg APIConfig, load func(string) (Config, error), start apiStartFunc) error {
+	token, err := expandSecrets(cfg.Token)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return errors.New("未設置接口令牌")
+	}
+	addr := cfg.Addr
+	if addr == "" {
+		addr = defaultAPIAddr
+	}
+	s := &apiServer{token: token, load: load, start: start}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.routes(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+	postStatus("HTTP 接口: http://" + ln.Addr().String())
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/profiles", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listProfiles())
+	})
+	mux.HandleFunc("GET /api/runs", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		runs := slices.Clone(s.runs)
+		s.mu.Unlock()
+		views := []apiRunView{}
+		for i := len(runs) - 1; i >= 0; i-- {
+			v := runs[i].view()
+			v.Report = nil
+			views = append(views, v)
+		}
+		writeJSON(w, http.StatusOK, views)
+	})
+	mux.HandleFunc("POST /api/runs", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Profile string `json:"profile"`
+			Groups  string `json:"groups"`
+			Tasks   string `json:"tasks"`
+			Force   bool   `json:"force"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.startRun(w, req.Profile, req.Groups, req.Tasks, req.Force)
+	})
+	mux.HandleFunc("POST /api/hooks/{profile}", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		s.startRun(w, r.PathValue("profile"), q.Get("groups"), q.Get("tasks"), q.Get("force") == "true")
+	})
+	mux.HandleFunc("GET /api/runs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if run := s.find(w, r); run != nil {
+			writeJSON(w, http.StatusOK, run.view())
+		}
+	})
+	mux.HandleFunc("POST /api/runs/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		if run := s.find(w, r); run != nil {
+			run.cancel()
+			writeJSON(w, http.StatusAccepted, run.view())
+		}
+	})
+	mux.HandleFunc("GET /api/runs/{id}/events", func(w http.ResponseWriter, r *http.Request) {
+		if run := s.find(w, r); run != nil {
+			streamEvents(w, r, run)
+		}
+	})
+	return s.auth(mux)
+}
+
+func (s *apiServer) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 只接受請求頭：查詢參數中的令牌會留在代理和訪問日誌裡
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("令牌無效"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *apiServer) find(w http.ResponseWriter, r *http.Request) *apiRun {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.runs {
+		if run.ID == id {
+			return run
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("找不到運行 %s", id))
+	return nil
+}
+
+// startRun 解析選擇並開始運行，成功時返回 202 和運行信息。
+func (s *apiServer) startRun(w http.ResponseWriter, profile, groups, tasks string, force bool) {
+	if profile == "" {
+		profile = defaultProfile
+	}
+	if !slices.Contains(listProfiles(), profile) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("找不到配置 %s", profile))
+		return
+	}
+	c, err := s.load(profile)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	order := groups
+	if order == "" {
+		order = formatGroupOrder(groupOrderOf(c))
+	}
+	if dup := duplicateTaskNames(c.Tasks); len(dup) > 0 && strings.TrimSpace(tasks) != "" {
+		writeError(w, http.StatusConflict, fmt.Errorf("任務名重複，無法按名稱選擇: %s", strings.Join(dup, ", ")))
+		return
+	}
+	var names []string
+	for _, n := range strings.Split(tasks, ",") {
+		if n = strings.TrimSpace(n); n == "" {
+			continue
+		}
+		if !hasTaskNamed(c.Tasks, n) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("找不到任務 %s", n))
+			return
+		}
+		names = append(names, n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.seq++
+	run := &apiRun{
+		ID:      fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), s.seq),
+		Profile: profile,
+		Start:   time.Now(),
+		cancel:  cancel,
+		wake:    make(chan struct{}),
+	}
+	s.mu.Unlock()
+	c.ForceCopy = force || c.ForceCopy
+	req := &runRequest{
+		Title: "接口觸發 " + profile, Profile: profile, Config: c, Order: order, Names: names,
+		Started: run.record,
+	}
+	done := s.start(ctx, req)
+	run.merged = req.Merged
+	s.mu.Lock()
+	s.runs = append(s.runs, run)
+	if len(s.runs) > apiRunsLimit {
+		s.runs = s.runs[len(s.runs)-apiRunsLimit:]
+	}
+	s.mu.Unlock()
+	go func() {
+		res := <-done
+		cancel()
+		run.finish(res)
+	}()
+	writeJSON(w, http.StatusAccepted, run.view())
+}
+
+// streamEvents 以 SSE 先重放已有事件再推送新事件，事件名為 Kind，數據為 JSON；
+// 運行結束時發送 done 事件。
+func streamEvents(w http.ResponseWriter, r *http.Request, run *apiRun) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("不支持流式輸出"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	sent := 0
+	for {
+		events, finished, wake := run.snapshot(sent)
+		for _, e := range events {
+			data, err := marshalRedacted(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Kind(), data)
+		}
+		sent += len(events)
+		if finished {
+			data, _ := marshalRedacted(run.view())
+			fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-wake:
+		}
+	}
+}
+
+// marshalRedacted 把 v 編碼為 JSON，其中的字符串先打碼再編碼。
+// 對編碼後的文本打碼會漏掉含引號、反斜杠或 < & 的密鑰，它們在 JSON 中已被轉義。
+func marshalRedacted(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var tree any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redactTree(tree))
+}
+
+func redactTree(v any) any {
+	switch v := v.(type) {
+	case string:
+		return redactSecrets(v)
+	case []any:
+		for i := range v {
+			v[i] = redactTree(v[i])
+		}
+	case map[string]any:
+		for k, x := range v {
+			v[k] = redactTree(x)
+		}
+	}
+	return v
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	data, _ := marshalRedacted(v)
+	w.Write(append(data, '\n'))
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
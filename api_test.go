@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 事件再多也不會丟掉 RunFinished，之後的事件不再記入本次運行。
+func TestAPIRunRecordsUntilRunFinished(t *testing.T) {
+	run := &apiRun{wake: make(chan struct{})}
+	run.record()
+	for i := range 5000 {
+		publish(FileCopied{Task: "site", Path: fmt.Sprintf("f%d.html", i)})
+	}
+	publish(RunFinished{ID: "r1"})
+	publish(Message{Msg: "下一次運行"})
+
+	events, _, _ := run.snapshot(0)
+	if len(events) != 5001 {
+		t.Fatalf("記錄了 %d 個事件，期望 5001 個", len(events))
+	}
+	if last, ok := events[len(events)-1].(RunFinished); !ok || last.ID != "r1" {
+		t.Fatalf("最後一個事件是 %#v", events[len(events)-1])
+	}
+}
+
+func TestProgressJSON(t *testing.T) {
+	data, err := json.Marshal(Progress{runProgress{Task: "site", Files: 3, FilesTotal: 10, Bytes: 300, BytesTotal: 1000, Rate: 100, ETA: 7 * time.Second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"task":"site"`, `"files_total":10`, `"bytes_total":1000`, `"rate":100`, `"eta_ms":7000`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("%s 中沒有 %s", data, want)
+		}
+	}
+	if strings.Contains(string(data), "ETA") {
+		t.Fatalf("%s 仍含納秒的 ETA", data)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// --- 歸檔任務 ---
+// ARCHIVE 任務把 Src 按與 SYNC 相同的過濾規則打包成 zip 或 tar.gz，寫到 Dst（任何同步端點）。
+// 條目按路徑排序，時間戳、權限和屬主固定，相同的內容總是得到相同的文件。
+// 文件名由 Archive 模板生成，Keep 大於 0 時只保留與模板匹配的最近 Keep 個歸檔。
+
+const defaultArchiveName = "{task}-{time}.zip"
+
+// archiveEpoch 是寫入歸檔的固定修改時間（zip 不能早於 1980 年）。
+var archiveEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var templateVar = regexp.MustCompile(`\{[a-z]+\}`)
+
+// archiveFormat 按擴展名返回 "zip" 或 "tar.gz"，不支持時返回空串。
+func archiveFormat(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	}
+	return ""
+}
+
+// archiveName 按模板生成本次的歸檔文件名。
+func archiveName(t TaskItem, now time.Time) string {
+	return expandTemplate(cmp.Or(t.Archive, defaultArchiveName), map[string]string{
+		"task": t.Name,
+		"date": now.Format("20060102"),
+		"time": now.Format("20060102-150405"),
+	})
+}
+
+// buildArchive 執行 ARCHIVE 任務。
+func buildArchive(ctx context.Context, t TaskItem, progress *progressTracker) (syncStats, error) {
+	name := archiveName(t, time.Now())
+	format := archiveFormat(name)
+	if format == "" {
+		return syncStats{}, fmt.Errorf("不支持的歸檔格式: %s（可用 .zip、.tar.gz、.tgz）", name)
+	}
+	src, dst, err := openSyncEnds(ctx, t.Src, t.Dst)
+	if err != nil {
+		return syncStats{}, err
+	}
+	defer src.Close()
+	defer dst.Close()
+
+	var stats syncStats
+	var entries []fileEntry
+	var total int64
+	filter := newTaskFilter(t)
+	err = walkBackend(ctx, src, "", func(e fileEntry, err error) error {
+		switch {
+		case err != nil:
+			// 源端讀不全時打出的包不完整，直接失敗
+			return err
+		case e.IsDir && filter.Excluded(e.Path):
+			return fs.SkipDir
+		case !e.IsDir && !filter.Accepts(e.Path):
+			return nil
+		}
+		entries = append(entries, e)
+		total += e.Size
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+	progress.StartTask(t.Name, len(entries), total)
+
+	if err := dst.Mkdir(ctx, parentDir(name)); err != nil {
+		return stats, err
+	}
+	pr, pw := io.Pipe()
+	counted := &progressReader{t: progress}
+	packed := make(chan struct{})
+	go func() {
+		defer close(packed)
+		pw.CloseWithError(writeArchive(ctx, pw, format, src, entries, t.Name, counted))
+	}()
+	// 先寫臨時文件，打包失敗時不會留下不完整、又會被保留策略計入的歸檔
+	tmp := name + ".tmp"
+	err = dst.Write(ctx, tmp, pr, fileEntry{Path: tmp, ModTime: time.Now()})
+	pr.CloseWithError(err)
+	if err == nil {
+		err = dst.Rename(ctx, tmp, name)
+	}
+	if err != nil {
+		<-packed
+		counted.Rollback()
+		dst.Remove(context.Background(), tmp)
+		return stats, fmt.Errorf("寫入歸檔 %s 失敗: %w", name, err)
+	}
+	for _, e := range entries {
+		if !e.IsDir {
+			stats.Copied++
+			stats.Bytes += e.Size
+		}
+	}
+	publish(CommandOutput{Task: t.Name, Line: fmt.Sprintf("已生成 %s（%d 個文件）", name, stats.Copied)})
+
+	if t.Keep > 0 {
+		if err := pruneArchives(ctx, dst, t, name, &stats); err != nil {
+			return stats, err
+		}
+	}
+	return stats, stats.Err()
+}
+
+// writeArchive 按 entries 的順序把源文件寫成歸檔，讀出的字節經 counted 計入進度。
+func writeArchive(ctx context.Context, w io.Writer, format string, src storageBackend, entries []fileEntry, task string, counted *progressReader) error {
+	add := func(e fileEntry, fw io.Writer) error {
+		r, err := src.Open(ctx, e.Path)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		counted.r = r
+		if _, err := io.Copy(fw, counted); err != nil {
+			return fmt.Errorf("%s: %w", e.Path, err)
+		}
+		counted.t.Add(1, 0)
+		publish(FileCopied{Task: task, Path: e.Path, Bytes: e.Size})
+		return nil
+	}
+	if format == "zip" {
+		zw := zip.NewWriter(w)
+		for _, e := range entries {
+			h := &zip.FileHeader{Name: e.Path, Method: zip.Deflate, Modified: archiveEpoch}
+			if e.IsDir {
+				h.Name += "/"
+				h.Method = zip.Store
+				h.SetMode(fs.ModeDir | 0755)
+			} else {
+				h.SetMode(0644)
+			}
+			fw, err := zw.CreateHeader(h)
+			if err != nil {
+				return err
+			}
+			if !e.IsDir {
+				if err := add(e, fw); err != nil {
+					return err
+				}
+			}
+		}
+		return zw.Close()
+	}
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.Path, Mode: 0644, Size: e.Size, ModTime: archiveEpoch, Typeflag: tar.TypeReg}
+		if e.IsDir {
+			h.Name += "/"
+			h.Mode, h.Size, h.Typeflag = 0755, 0, tar.TypeDir
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			return err
+		}
+		if !e.IsDir {
+			if err := add(e, tw); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// archivePattern 把文件名模板轉成只匹配本任務歷次歸檔的正則：{date}、{time} 匹配對應格式的數字，
+// 其餘部分（含任務名）按字面匹配，site-{date}.zip 不會波及 site-beta-20240101.zip。
+func archivePattern(t TaskItem) *regexp.Regexp {
+	tmpl := cmp.Or(t.Archive, defaultArchiveName)
+	vars := map[string]string{"task": regexp.QuoteMeta(t.Name), "date": `\d{8}`, "time": `\d{8}-\d{6}`}
+	var b strings.Builder
+	last := 0
+	for _, m := range templateVar.FindAllStringIndex(tmpl, -1) {
+		b.WriteString(regexp.QuoteMeta(tmpl[last:m[0]]))
+		v, ok := vars[tmpl[m[0]+1:m[1]-1]]
+		if !ok {
+			v = regexp.QuoteMeta(tmpl[m[0]:m[1]]) // 未知的佔位符在文件名中原樣保留
+		}
+		b.WriteString(v)
+		last = m[1]
+	}
+	b.WriteString(regexp.QuoteMeta(tmpl[last:]))
+	return regexp.MustCompile("^" + b.String() + "$")
+}
+
+// pruneArchives 刪除與文件名模板匹配的舊歸檔，保留最近 Keep 個（含剛寫入的 current）。
+func pruneArchives(ctx context.Context, dst storageBackend, t TaskItem, current string, stats *syncStats) error {
+	pattern := archivePattern(t)
+	list, err := dst.List(ctx, parentDir(current))
+	if err != nil {
+		return err
+	}
+	var old []fileEntry
+	for _, e := range list {
+		if pattern.MatchString(e.Path) && !e.IsDir && e.Path != current {
+			old = append(old, e)
+		}
+	}
+	// 新的在前：先按修改時間，再按文件名（模板中的時間使文件名有序）
+	slices.SortFunc(old, func(a, b fileEntry) int {
+		return cmp.Or(b.ModTime.Compare(a.ModTime), strings.Compare(b.Path, a.Path))
+	})
+	for i, e := range old {
+		if i < t.Keep-1 {
+			continue
+		}
+		publish(FileDeleted{Task: t.Name, Path: e.Path})
+		if err := dst.Remove(ctx, e.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			stats.fail(err)
+			continue
+		}
+		stats.Deleted++
+	}
+	return nil
+}
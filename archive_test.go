@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// 只清理本任務按模板生成的歸檔：名字相近的其他任務、手工放入的文件和任務名中的通配符都不受影響。
+func TestPruneArchives(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"site[1]-20240101-000000.zip", "site[1]-20240102-000000.zip", "site[1]-20240103-000000.zip",
+		"site[1]-beta-20240101-000000.zip", "site1-20240101-000000.zip", "site[1]-latest.zip",
+		"site[1]-20240101-000000.zip.bak",
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range names {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("zip"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mt := base.Add(time.Duration(i) * time.Hour)
+		if err := os.Chtimes(p, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dst, err := openBackend(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	task := TaskItem{Name: "site[1]", Type: TaskArchive, Keep: 2}
+	var stats syncStats
+	if err := pruneArchives(context.Background(), dst, task, "site[1]-20240103-000000.zip", &stats); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	want := []string{
+		"site1-20240101-000000.zip", "site[1]-20240101-000000.zip.bak", "site[1]-20240102-000000.zip",
+		"site[1]-20240103-000000.zip", "site[1]-beta-20240101-000000.zip", "site[1]-latest.zip",
+	}
+	if !slices.Equal(left, want) || stats.Deleted != 1 {
+		t.Fatalf("清理後剩下 %v（刪除 %d 個），期望 %v", left, stats.Deleted, want)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// --- 存儲後端 ---
+// 同步任務的源和目標可以是普通路徑，也可以是 scheme://... 形式的地址。
+// 每種地址對應一個存儲後端，引擎只通過後端接口讀寫，過濾、比較、鏡像刪除和進度
+// 對所有後端一致。後端內的路徑都相對於地址指向的根目錄，以 / 分隔，根目錄為 ""。
+
+// fileEntry 是後端中一個文件或目錄的元數據。
+type fileEntry struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+	Hash    string // 內容摘要，如對象存儲的 ETag；後端不提供時為空
+	// SourceTime 表示 ModTime 是寫入時另外記下的源文件修改時間。
+	// 只對修改時間不可寫、需要自行記錄的後端（如 WebDAV）有意義
+	SourceTime bool
+}
+
+type storageBackend interface {
+	// List 列出目錄下一層的條目，目錄不存在時返回 fs.ErrNotExist。
+	List(ctx context.Context, dir string) ([]fileEntry, error)
+	Stat(ctx context.Context, p string) (fileEntry, error)
+	Open(ctx context.Context, p string) (io.ReadCloser, error)
+	// Write 寫入文件並盡量保留 e.ModTime，父目錄需已存在。
+	Write(ctx context.Context, p string, r io.Reader, e fileEntry) error
+	// Remove 刪除文件或空目錄。
+	Remove(ctx context.Context, p string) error
+	// Mkdir 創建目錄及缺少的父目錄，已存在不算錯誤。
+	Mkdir(ctx context.Context, p string) error
+	Rename(ctx context.Context, from, to string) error
+	Close() error
+}
+
+// contentComparer 由修改時間不可靠、需按內容判斷變化的後端實現（如對象存儲）。
+type contentComparer interface {
+	// Same 判斷目標條目 d 與源 src 中的條目 s 內容是否一致。
+	Same(ctx context.Context, src storageBackend, s, d fileEntry) (bool, error)
+}
+
+// transferLimiter 由同時能進行的傳輸數有限的後端實現（如連接池、只能順序讀取的歸檔），返回上限。
+type transferLimiter interface {
+	MaxTransfers() int
+}
+
+// defaultWorkers 是同步任務未指定 workers 時的並行傳輸數。
+const defaultWorkers = 4
+
+// syncWorkers 返回兩端之間並行傳輸的文件數：任務指定的值或默認值，再受兩端的上限約束。
+func syncWorkers(src, dst storageBackend, workers int) int {
+	n := cmp.Or(workers, defaultWorkers)
+	for _, b := range []storageBackend{src, dst} {
+		if l, ok := b.(transferLimiter); ok && l.MaxTransfers() > 0 {
+			n = min(n, l.MaxTransfers())
+		}
+	}
+	return max(n, 1)
+}
+
+// backendSchemes 把地址的 scheme 映射到打開對應後端的函數。
+var backendSchemes = map[string]func(ctx context.Context, u *url.URL) (storageBackend, error){
+	"file": func(ctx context.Context, u *url.URL) (storageBackend, error) {
+		return openLocal(fileURLPath(u))
+	},
+	"sftp":    openSFTP,
+	"ftp":     openFTP,
+	"ftps":    openFTP,
+	"s3":      openS3,
+	"webdav":  openWebDAV,
+	"webdavs": openWebDAV,
+}
+
+// secretParams 是地址中需要在日誌裡隱去的查詢參數。
+var secretParams = []string{"passphrase"}
+
+// parseEndpoint 解析 scheme://... 形式的地址；普通路徑（包括 C:\ 這類盤符路徑）返回 nil。
+func parseEndpoint(s string) (*url.URL, error) {
+	if !strings.Contains(s, "://") {
+		return nil, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("地址格式錯誤: %w", err)
+	}
+	if _, ok := backendSchemes[u.Scheme]; !ok {
+		return nil, fmt.Errorf("不支持的地址類型: %s", u.Scheme)
+	}
+	return u, nil
+}
+
+// openBackend 按地址打開存儲後端，用完需 Close。
+func openBackend(ctx context.Context, endpoint string) (storageBackend, error) {
+	u, err := parseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return openLocal(endpoint)
+	}
+	return backendSchemes[u.Scheme](ctx, u)
+}
+
+// localPath 返回地址對應的本地路徑；遠程地址返回 false。監視和配置檢查只處理本地目錄。
+func localPath(endpoint string) (string, bool) {
+	u, err := parseEndpoint(endpoint)
+	switch {
+	case err != nil:
+		return "", false
+	case u == nil:
+		return endpoint, true
+	case u.Scheme == "file":
+		return fileURLPath(u), true
+	}
+	return "", false
+}
+
+// fileURLPath 把 file:// 地址轉回本地路徑：file:///C:/site 為盤符路徑，file://host/share 為 UNC 路徑。
+func fileURLPath(u *url.URL) string {
+	p := u.Path
+	if u.Host != "" && u.Host != "localhost" {
+		return filepath.FromSlash("//" + u.Host + p)
+	}
+	if len(p) >= 3 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
+
+// redactEndpoint 隱去地址中的密碼和敏感參數，用於日誌和錯誤信息。
+func redactEndpoint(endpoint string) string {
+	u, err := parseEndpoint(endpoint)
+	if err != nil || u == nil {
+		return endpoint
+	}
+	q := u.Query()
+	for _, k := range secretParams {
+		if q.Has(k) {
+			q.Set(k, "xxxxx")
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.Redacted()
+}
+
+// walkBackend 從 dir 開始遞歸列出條目，父目錄先於子條目，同層按名稱排序。
+// 列出失敗時以該目錄和錯誤調用 fn；fn 對目錄返回 fs.SkipDir 時不再進入。
+func walkBackend(ctx context.Context, b storageBackend, dir string, fn func(e fileEntry, err error) error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	entries, err := b.List(ctx, dir)
+	if err != nil {
+		return fn(fileEntry{Path: dir, IsDir: true}, err)
+	}
+	slices.SortFunc(entries, func(a, b fileEntry) int { return strings.Compare(a.Path, b.Path) })
+	for _, e := range entries {
+		err := fn(e, nil)
+		if errors.Is(err, fs.SkipDir) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if e.IsDir {
+			if err := walkBackend(ctx, b, e.Path, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// joinPath 拼接後端內的相對路徑。
+func joinPath(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return path.Join(dir, name)
+}
+
+// parentDir 返回後端路徑的父目錄，頂層條目的父目錄為 ""。
+func parentDir(p string) string {
+	if d := path.Dir(p); d != "." {
+		return d
+	}
+	return ""
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- 歸檔源 ---
+// 以 .zip、.tar.gz、.tgz 結尾的本地文件作為只讀後端，同步時把條目直接流式寫到目標，不解壓到臨時目錄。
+// 打開時讀一遍目錄建立索引，歸檔中省略的目錄條目按文件路徑補齊。
+// zip 可以隨機讀取；tar.gz 只能順序解壓，讀取時持有游標，請求的條目在游標之前才從頭重新解壓。
+// 同步按路徑順序複製，與 ARCHIVE 任務打出的包順序一致，通常只需解壓一遍。
+// zip 中只有 DOS 時間的條目按本機時區解釋；DOS 時間精確到 2 秒，與原文件比較時奇數秒的文件會重新複製一次。
+
+var errReadOnlyArchive = errors.New("歸檔文件只能作為同步源")
+
+type archiveBackend struct {
+	file     string
+	entries  map[string]fileEntry
+	children map[string][]string
+
+	zip   *zip.ReadCloser
+	files map[string]*zip.File
+
+	order map[string]int // tar 條目在流中的序號
+	mu    sync.Mutex     // tar 游標，同時只能讀一個條目
+	f     *os.File
+	gz    *gzip.Reader
+	tr    *tar.Reader
+	next  int // 下一次 Next 返回的條目序號
+}
+
+func openArchive(file string) (storageBackend, error) {
+	b := &archiveBackend{
+		file:     file,
+		entries:  map[string]fileEntry{"": {IsDir: true}},
+		children: map[string][]string{},
+	}
+	var err error
+	if archiveFormat(file) == "zip" {
+		err = b.indexZip()
+	} else {
+		err = b.indexTar()
+	}
+	if err != nil {
+		b.Close()
+		return nil, fmt.Errorf("讀取歸檔 %s 失敗: %w", file, err)
+	}
+	return b, nil
+}
+
+// archiveEntryPath 規範化歸檔內的路徑，去掉開頭的 / 和 ./，越出根目錄的 .. 被截斷。
+func archiveEntryPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, `\`, "/")), "/")
+}
+
+// add 記錄一個條目，並補齊缺失的上級目錄。
+func (b *archiveBackend) add(e fileEntry) {
+	if _, ok := b.entries[e.Path]; !ok && e.Path != "" {
+		dir := parentDir(e.Path)
+		b.children[dir] = append(b.children[dir], e.Path)
+		if _, ok := b.entries[dir]; !ok {
+			b.add(fileEntry{Path: dir, IsDir: true, ModTime: e.ModTime})
+		}
+	}
+	if e.Path != "" {
+		b.entries[e.Path] = e
+	}
+}
+
+func (b *archiveBackend) indexZip() error {
+	zr, err := zip.OpenReader(b.file)
+	if err != nil {
+		return err
+	}
+	b.zip, b.files = zr, map[string]*zip.File{}
+	for _, f := range zr.File {
+		p := archiveEntryPath(f.Name)
+		mode := f.Mode()
+		switch {
+		case p == "":
+		case mode.IsDir():
+			b.add(fileEntry{Path: p, IsDir: true, ModTime: zipModTime(f)})
+		case mode.IsRegular():
+			b.add(fileEntry{Path: p, Size: int64(f.UncompressedSize64), ModTime: zipModTime(f)})
+			b.files[p] = f
+		}
+	}
+	return nil
+}
+
+// zipModTime 返回 zip 條目的修改時間。沒有擴展時間戳時只有打包機器的本地 DOS 時間，
+// archive/zip 把它當作 UTC，這裡改按本機時區解釋，否則與目標比較時差出時區偏移，每次都重新複製。
+func zipModTime(f *zip.File) time.Time {
+	if zipHasExtTime(f.Extra) {
+		return f.Modified
+	}
+	m := f.Modified
+	return time.Date(m.Year(), m.Month(), m.Day(), m.Hour(), m.Minute(), m.Second(), 0, time.Local)
+}
+
+// zipHasExtTime 報告擴展字段中是否有 archive/zip 認得的時間戳（NTFS、Unix、擴展時間戳）。
+func zipHasExtTime(extra []byte) bool {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		n := int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra)-4 < n {
+			break
+		}
+		switch id {
+		case 0x000a, 0x5855, 0x5455:
+			return true
+		}
+		extra = extra[4+n:]
+	}
+	return false
+}
+
+func (b *archiveBackend) indexTar() error {
+	f, err := os.Open(b.file)
+	if err != nil {
+		return err
+	}
+	b.f, b.order = f, map[string]int{}
+	if err := b.rewind(); err != nil {
+		return err
+	}
+	for ; ; b.next++ {
+		h, err := b.tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		p := archiveEntryPath(h.Name)
+		switch {
+		case p == "":
+		case h.Typeflag == tar.TypeDir:
+			b.add(fileEntry{Path: p, IsDir: true, ModTime: h.ModTime})
+		case h.FileInfo().Mode().IsRegular():
+			b.add(fileEntry{Path: p, Size: h.Size, ModTime: h.ModTime})
+			b.order[p] = b.next
+		}
+	}
+}
+
+// rewind 把 tar 游標移回開頭。
+func (b *archiveBackend) rewind() error {
+	if _, err := b.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	br := bufio.NewReader(b.f)
+	var err error
+	if b.gz == nil {
+		b.gz, err = gzip.NewReader(br)
+	} else {
+		err = b.gz.Reset(br)
+	}
+	if err != nil {
+		return err
+	}
+	b.tr, b.next = tar.NewReader(b.gz), 0
+	return nil
+}
+
+// MaxTransfers 對 tar.gz 返回 1：並行讀取會打亂順序，使游標反覆從頭解壓。zip 不限制。
+func (b *archiveBackend) MaxTransfers() int {
+	if b.zip != nil {
+		return 0
+	}
+	return 1
+}
+
+func (b *archiveBackend) List(ctx context.Context, dir string) ([]fileEntry, error) {
+	if e, ok := b.entries[dir]; !ok || !e.IsDir {
+		return nil, &fs.PathError{Op: "list", Path: dir, Err: fs.ErrNotExist}
+	}
+	entries := make([]fileEntry, 0, len(b.children[dir]))
+	for _, p := range b.children[dir] {
+		entries = append(entries, b.entries[p])
+	}
+	return entries, nil
+}
+
+func (b *archiveBackend) Stat(ctx context.Context, p string) (fileEntry, error) {
+	e, ok := b.entries[p]
+	if !ok {
+		return fileEntry{}, &fs.PathError{Op: "stat", Path: p, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+func (b *archiveBackend) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	if b.zip != nil {
+		f, ok := b.files[p]
+		if !ok {
+			return nil, &fs.PathError{Op: "open", Path: p, Err: fs.ErrNotExist}
+		}
+		return f.Open()
+	}
+	n, ok := b.order[p]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: p, Err: fs.ErrNotExist}
+	}
+	b.mu.Lock()
+	if n < b.next {
+		if err := b.rewind(); err != nil {
+			b.mu.Unlock()
+			return nil, err
+		}
+	}
+	for ; b.next <= n; b.next++ {
+		if _, err := b.tr.Next(); err != nil {
+			b.rewind() // 狀態未知，下次從頭開始
+			b.mu.Unlock()
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return &tarEntryReader{r: b.tr, unlock: b.mu.Unlock}, nil
+}
+
+// tarEntryReader 讀取當前 tar 條目，關閉時釋放游標。
+type tarEntryReader struct {
+	r      io.Reader
+	unlock func()
+	once   sync.Once
+}
+
+func (r *tarEntryReader) Read(p []byte) (int, error) { return r.r.Read(p) }
+
+func (r *tarEntryReader) Close() error {
+	r.once.Do(r.unlock)
+	return nil
+}
+
+func (b *archiveBackend) Write(ctx context.Context, p string, r io.Reader, e fileEntry) error {
+	return errReadOnlyArchive
+}
+
+func (b *archiveBackend) Remove(ctx context.Context, p string) error { return errReadOnlyArchive }
+
+func (b *archiveBackend) Mkdir(ctx context.Context, p string) error { return errReadOnlyArchive }
+
+func (b *archiveBackend) Rename(ctx context.Context, from, to string) error {
+	return errReadOnlyArchive
+}
+
+func (b *archiveBackend) Close() error {
+	if b.zip != nil {
+		return b.zip.Close()
+	}
+	if b.f != nil {
+		return b.f.Close()
+	}
+	return nil
+}
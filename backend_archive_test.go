@@ -0,0 +1,176 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+type testArchiveEntry struct {
+	name string
+	body string
+}
+
+// writeTestArchive 按擴展名打出 zip 或 tar.gz，只包含文件條目，不寫目錄。
+func writeTestArchive(t *testing.T, name string, mtime time.Time, entries []testArchiveEntry) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if archiveFormat(name) == "zip" {
+		zw := zip.NewWriter(f)
+		for _, e := range entries {
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: mtime})
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.WriteString(w, e.body)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body)), ModTime: mtime, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, e.body)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func readBackendFile(t *testing.T, b storageBackend, p string) string {
+	t.Helper()
+	r, err := b.Open(context.Background(), p)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", p, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("讀取 %s: %v", p, err)
+	}
+	return string(data)
+}
+
+// 路徑規範化、補齊省略的目錄，以及 tar.gz 亂序讀取時從頭重新解壓。
+func TestArchiveBackend(t *testing.T) {
+	mtime := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	entries := []testArchiveEntry{
+		{"./index.html", "<h1>home</h1>"},
+		{"posts/2024/hello.html", "<p>hello</p>"},
+		{"../../evil.txt", "evil"},
+		{`static\app.css`, "body{}"},
+		{"/posts/about.html", "<p>about</p>"},
+	}
+	want := map[string]string{
+		"index.html":            "<h1>home</h1>",
+		"posts/2024/hello.html": "<p>hello</p>",
+		"evil.txt":              "evil",
+		"static/app.css":        "body{}",
+		"posts/about.html":      "<p>about</p>",
+	}
+	for _, name := range []string{"site.zip", "site.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			b, err := openBackend(context.Background(), writeTestArchive(t, name, mtime, entries))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+
+			list := func(dir string) []string {
+				es, err := b.List(context.Background(), dir)
+				if err != nil {
+					t.Fatalf("List(%q): %v", dir, err)
+				}
+				var names []string
+				for _, e := range es {
+					names = append(names, e.Path)
+				}
+				slices.Sort(names)
+				return names
+			}
+			if got := list(""); !slices.Equal(got, []string{"evil.txt", "index.html", "posts", "static"}) {
+				t.Fatalf("根目錄 = %v", got)
+			}
+			if got := list("posts"); !slices.Equal(got, []string{"posts/2024", "posts/about.html"}) {
+				t.Fatalf("posts = %v", got)
+			}
+			if e, err := b.Stat(context.Background(), "posts/2024"); err != nil || !e.IsDir {
+				t.Fatalf("省略的目錄 posts/2024 = %+v, %v", e, err)
+			}
+			e, err := b.Stat(context.Background(), "posts/2024/hello.html")
+			if err != nil || e.Size != 12 || !e.ModTime.Equal(mtime) {
+				t.Fatalf("Stat = %+v, %v，期望 12 字節、修改時間 %v", e, err, mtime)
+			}
+
+			// 倒序、再跳回中間讀取，tar.gz 需要回到開頭重新解壓
+			for _, p := range []string{"posts/about.html", "index.html", "evil.txt", "static/app.css", "posts/2024/hello.html", "evil.txt"} {
+				if got := readBackendFile(t, b, p); got != want[p] {
+					t.Fatalf("%s = %q，期望 %q", p, got, want[p])
+				}
+			}
+			if _, err := b.Open(context.Background(), "posts"); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("打開目錄: %v", err)
+			}
+			if err := b.Write(context.Background(), "new.html", nil, fileEntry{}); err != errReadOnlyArchive {
+				t.Fatalf("Write = %v", err)
+			}
+		})
+	}
+}
+
+// 沒有擴展時間戳的 zip 條目記錄的是本地時間，不能按 UTC 讀出。
+func TestArchiveZipDOSTime(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+8", 8*3600)
+	t.Cleanup(func() { time.Local = local })
+
+	p := filepath.Join(t.TempDir(), "old.zip")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	// 2024-03-04 05:06:08，DOS 時間精確到 2 秒
+	h := &zip.FileHeader{Name: "index.html", ModifiedDate: 44<<9 | 3<<5 | 4, ModifiedTime: 5<<11 | 6<<5 | 4}
+	w, err := zw.CreateHeader(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "hi")
+	zw.Close()
+	f.Close()
+
+	b, err := openBackend(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	e, err := b.Stat(context.Background(), "index.html")
+	want := time.Date(2024, 3, 4, 5, 6, 8, 0, time.Local)
+	if err != nil || !e.ModTime.Equal(want) {
+		t.Fatalf("修改時間 = %v, %v，期望 %v", e.ModTime, err, want)
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- FTP 後端 ---
+// ftp://用戶:密碼@主機[:端口]/路徑?connections=4，ftps:// 在同一端口上用 AUTH TLS 升級（顯式 TLS），數據連接同樣加密。
+//
+// 只用被動模式；列表依賴 MLSD/MLST 取得精確的大小和 UTC 修改時間，不支持的服務器會被拒絕。
+// 控制連接放在池中複用，最多同時打開 connections 條，並行傳輸各佔一條。
+// 支持 MFMT 的服務器上傳後設為源文件的修改時間，之後按大小和修改時間精確比較；
+// 否則大小相同且目標不早於源文件即視為未變化，大小不變的修改或換成更舊的文件可能漏掉。
+// 路徑以 /~/ 開頭或為空時相對於登錄目錄。
+
+const (
+	ftpDialTimeout  = 30 * time.Second
+	ftpDefaultConns = 4
+)
+
+type ftpBackend struct {
+	root  string
+	conns int
+	mfmt  bool // 服務器支持 MFMT，目標的修改時間就是源文件的
+	dial  func(ctx context.Context) (*ftpConn, error)
+	idle  chan *ftpConn
+	slots chan struct{} // 已打開的連接數
+}
+
+func openFTP(ctx context.Context, u *url.URL) (storageBackend, error) {
+	conns := ftpDefaultConns
+	if s := u.Query().Get("connections"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("ftp connections 必須是正整數: %s", s)
+		}
+		conns = n
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "21")
+	}
+	user := u.User.Username()
+	if user == "" {
+		user = "anonymous"
+	}
+	pass, _ := u.User.Password()
+	var tlsConf *tls.Config
+	if u.Scheme == "ftps" {
+		tlsConf = &tls.Config{
+			ServerName:         u.Hostname(),
+			ClientSessionCache: tls.NewLRUClientSessionCache(conns),
+		}
+	}
+	root := u.Path
+	switch {
+	case root == "/~":
+		root = ""
+	case strings.HasPrefix(root, "/~/"):
+		root = root[3:]
+	}
+	b := &ftpBackend{
+		root:  root,
+		conns: conns,
+		idle:  make(chan *ftpConn, conns),
+		slots: make(chan struct{}, conns),
+		dial: func(ctx context.Context) (*ftpConn, error) {
+			ctx, cancel := context.WithTimeout(ctx, ftpDialTimeout)
+			defer cancel()
+			return dialFTP(ctx, addr, user, pass, tlsConf)
+		},
+	}
+	// 先建立一條連接，地址或賬號有誤時在開始同步前報錯
+	c, err := b.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, b.mfmt = c.feats["MFMT"]
+	b.put(c, nil)
+	return b, nil
+}
+
+// MaxTransfers 讓同步的並行數不超過連接數，多出的傳輸只會排隊等連接。
+func (b *ftpBackend) MaxTransfers() int { return b.conns }
+
+// get 取一條空閒連接；沒有空閒且未達上限時新建，否則等待別的操作歸還。
+func (b *ftpBackend) get(ctx context.Context) (*ftpConn, error) {
+	select {
+	case c := <-b.idle:
+		return c, nil
+	default:
+	}
+	select {
+	case c := <-b.idle:
+		return c, nil
+	case b.slots <- struct{}{}:
+		c, err := b.dial(ctx)
+		if err != nil {
+			<-b.slots
+			return nil, err
+		}
+		return c, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// put 歸還連接。服務器的錯誤應答不影響連接；網絡錯誤或被取消的連接狀態未知，直接關閉。
+func (b *ftpBackend) put(c *ftpConn, err error) {
+	var fe *ftpError
+	if err != nil && !errors.As(err, &fe) {
+		c.conn.Close()
+		<-b.slots
+		return
+	}
+	b.idle <- c
+}
+
+// with 在一條連接上執行 fn，ctx 取消時打斷阻塞的讀寫。
+func (b *ftpBackend) with(ctx context.Context, fn func(c *ftpConn) error) error {
+	c, err := b.get(ctx)
+	if err != nil {
+		return err
+	}
+	stop := c.watch(ctx)
+	err = fn(c)
+	if !stop() {
+		b.put(c, ctx.Err())
+		return errors.Join(ctx.Err(), err)
+	}
+	b.put(c, err)
+	return err
+}
+
+func (b *ftpBackend) path(p string) string {
+	if b.root == "" {
+		return p
+	}
+	return path.Join(b.root, p)
+}
+
+// ftpArg 拼出帶可選路徑參數的命令，空路徑表示登錄目錄。
+func ftpArg(verb, p string) (string, []any) {
+	if p == "" {
+		return verb, nil
+	}
+	return verb + " %s", []any{p}
+}
+
+func (b *ftpBackend) List(ctx context.Context, dir string) ([]fileEntry, error) {
+	var entries []fileEntry
+	err := b.with(ctx, func(c *ftpConn) error {
+		format, args := ftpArg("MLSD", b.path(dir))
+		dc, err := c.transfer(ctx, format, args...)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(dc)
+		dc.Close()
+		if ferr := c.finish(); err == nil {
+			err = ferr
+		}
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			name, e, ok := parseMLSx(strings.TrimRight(line, "\r"))
+			if !ok {
+				continue
+			}
+			e.Path = joinPath(dir, name)
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func (b *ftpBackend) Stat(ctx context.Context, p string) (fileEntry, error) {
+	var e fileEntry
+	err := b.with(ctx, func(c *ftpConn) error {
+		var err error
+		e, err = c.stat(b.path(p))
+		return err
+	})
+	e.Path = p
+	return e, err
+}
+
+// stat 用 MLST 查詢單個路徑，事實行在多行應答的中間並以空格開頭。
+func (c *ftpConn) stat(p string) (fileEntry, error) {
+	format, args := ftpArg("MLST", p)
+	_, msg, err := c.cmd(2, format, args...)
+	if err != nil {
+		return fileEntry{}, err
+	}
+	for _, line := range strings.Split(msg, "\n") {
+		if !strings.HasPrefix(line, " ") {
+			continue
+		}
+		if _, e, ok := parseMLSx(strings.TrimSpace(line)); ok {
+			return e, nil
+		}
+	}
+	return fileEntry{}, fmt.Errorf("ftp MLST %s: 應答格式錯誤", p)
+}
+
+func (b *ftpBackend) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	c, err := b.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stop := c.watch(ctx)
+	dc, err := c.transfer(ctx, "RETR %s", b.path(p))
+	if err != nil {
+		stop()
+		b.put(c, err)
+		return nil, err
+	}
+	return &ftpReader{Conn: dc, b: b, c: c, stop: stop}, nil
+}
+
+// ftpReader 在讀完並關閉後才把控制連接還回池中。
+type ftpReader struct {
+	net.Conn
+	b    *ftpBackend
+	c    *ftpConn
+	stop func() bool
+}
+
+func (r *ftpReader) Close() error {
+	r.Conn.Close()
+	err := r.c.finish()
+	if !r.stop() {
+		r.b.put(r.c, context.Canceled)
+		return errors.Join(context.Canceled, err)
+	}
+	r.b.put(r.c, err)
+	return err
+}
+
+func (b *ftpBackend) Same(ctx context.Context, src storageBackend, s, d fileEntry) (bool, error) {
+	if b.mfmt {
+		return sameEntry(s, d), nil
+	}
+	return s.Size == d.Size && d.ModTime.After(s.ModTime.Add(-time.Second)), nil
+}
+
+// Write 先上傳到同目錄的臨時文件，設置修改時間後改名。
+func (b *ftpBackend) Write(ctx context.Context, p string, r io.Reader, e fileEntry) error {
+	target := b.path(p)
+	tmp := path.Join(path.Dir(target), ".hugo-sync-"+path.Base(target)+".tmp")
+	return b.with(ctx, func(c *ftpConn) error {
+		err := c.store(ctx, tmp, r)
+		if _, ok := c.feats["MFMT"]; err == nil && ok && !e.ModTime.IsZero() {
+			_, _, err = c.cmd(2, "MFMT %s %s", e.ModTime.UTC().Format("20060102150405"), tmp)
+		}
+		if err == nil {
+			err = c.rename(tmp, target)
+		}
+		var fe *ftpError
+		if err != nil && errors.As(err, &fe) {
+			c.cmd(0, "DELE %s", tmp)
+		}
+		return err
+	})
+}
+
+func (c *ftpConn) store(ctx context.Context, p string, r io.Reader) error {
+	dc, err := c.transfer(ctx, "STOR %s", p)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dc, r)
+	if cerr := dc.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// 數據未完整發出，控制連接上的最終應答不可信
+		return err
+	}
+	return c.finish()
+}
+
+// rename 覆蓋已存在的目標；部分服務器拒絕覆蓋，此時先刪除目標再改名。
+func (c *ftpConn) rename(from, to string) error {
+	move := func() error {
+		if _, _, err := c.cmd(3, "RNFR %s", from); err != nil {
+			return err
+		}
+		_, _, err := c.cmd(2, "RNTO %s", to)
+		return err
+	}
+	err := move()
+	var fe *ftpError
+	if errors.As(err, &fe) && fe.Cmd == "RNTO" {
+		if _, _, derr := c.cmd(2, "DELE %s", to); derr == nil {
+			err = move()
+		}
+	}
+	return err
+}
+
+// Remove 先按文件刪除，失敗且確實是目錄時改用 RMD。
+func (b *ftpBackend) Remove(ctx context.Context, p string) error {
+	return b.with(ctx, func(c *ftpConn) error {
+		full := b.path(p)
+		_, _, err := c.cmd(2, "DELE %s", full)
+		if err == nil {
+			return nil
+		}
+		if e, serr := c.stat(full); serr == nil && e.IsDir {
+			_, _, err = c.cmd(2, "RMD %s", full)
+		}
+		return err
+	})
+}
+
+func (b *ftpBackend) Mkdir(ctx context.Context, p string) error {
+	return b.with(ctx, func(c *ftpConn) error {
+		return c.mkdirAll(b.path(p))
+	})
+}
+
+// mkdirAll 逐級創建目錄，根目錄本身不存在時也一併創建。
+func (c *ftpConn) mkdirAll(p string) error {
+	if p == "" || p == "." || p == "/" {
+		return nil
+	}
+	if e, err := c.stat(p); err == nil {
+		if e.IsDir {
+			return nil
+		}
+		return fmt.Errorf("ftp mkdir %s: 已存在同名文件", p)
+	}
+	if err := c.mkdirAll(path.Dir(p)); err != nil {
+		return err
+	}
+	_, _, err := c.cmd(2, "MKD %s", p)
+	if err != nil {
+		// 並發創建時可能已被別的連接建好
+		if e, serr := c.stat(p); serr == nil && e.IsDir {
+			return nil
+		}
+	}
+	return err
+}
+
+func (b *ftpBackend) Rename(ctx context.Context, from, to string) error {
+	return b.with(ctx, func(c *ftpConn) error {
+		return c.rename(b.path(from), b.path(to))
+	})
+}
+
+func (b *ftpBackend) Close() error {
+	for {
+		select {
+		case c := <-b.idle:
+			c.Close()
+			<-b.slots
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFTP 是把本地目錄當作文件系統的最小 FTP 服務器：只支持被動模式（EPSV）、
+// MLSD/MLST、MFMT 和 AUTH TLS，命令參數按絕對路徑解釋。
+type fakeFTP struct {
+	root string
+	tls  *tls.Config
+	cert *x509.Certificate
+
+	noMFMT bool // FEAT 不列出 MFMT
+
+	mu       sync.Mutex
+	stores   int // 正在進行的 STOR 數
+	maxStore int
+}
+
+func startFakeFTP(t *testing.T) (*fakeFTP, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:   time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	f := &fakeFTP{
+		root: t.TempDir(),
+		tls:  &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+		cert: cert,
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f, l.Addr().String()
+}
+
+func (f *fakeFTP) local(p string) string { return filepath.Join(f.root, filepath.FromSlash(p)) }
+
+func mlsxFacts(fi os.FileInfo) string {
+	kind := "file"
+	if fi.IsDir() {
+		kind = "dir"
+	}
+	return fmt.Sprintf("type=%s;size=%d;modify=%s;", kind, fi.Size(), fi.ModTime().UTC().Format("20060102150405"))
+}
+
+func (f *fakeFTP) serve(c net.Conn) {
+	defer func() { c.Close() }()
+	r := bufio.NewReader(c)
+	var w io.Writer = c
+	reply := func(format string, args ...any) { fmt.Fprintf(w, format+"\r\n", args...) }
+	var (
+		prot    bool
+		pasv    net.Listener
+		renFrom string
+		authed  bool
+	)
+	data := func() (net.Conn, error) {
+		defer pasv.Close()
+		dc, err := pasv.Accept()
+		if err == nil && prot {
+			dc = tls.Server(dc, f.tls)
+		}
+		return dc, err
+	}
+	reply("220 fake ftp")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		if !authed && verb != "AUTH" && verb != "USER" && verb != "PASS" && verb != "FEAT" {
+			reply("530 not logged in")
+			continue
+		}
+		switch verb {
+		case "AUTH":
+			reply("234 ok")
+			tc := tls.Server(c, f.tls)
+			c, w, r = tc, tc, bufio.NewReader(tc)
+		case "USER":
+			reply("331 password please")
+		case "PASS":
+			if arg != "p@ss:/#word" {
+				reply("530 bad password")
+				continue
+			}
+			authed = true
+			reply("230 ok")
+		case "PBSZ", "TYPE", "OPTS":
+			reply("200 ok")
+		case "PROT":
+			prot = arg == "P"
+			reply("200 ok")
+		case "FEAT":
+			mfmt := " MFMT\r\n"
+			if f.noMFMT {
+				mfmt = ""
+			}
+			reply("211-Features:\r\n MLST type*;size*;modify*;\r\n%s UTF8\r\n211 End", mfmt)
+		case "EPSV":
+			if pasv, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				reply("425 no listener")
+				continue
+			}
+			reply("229 Entering Extended Passive Mode (|||%d|)", pasv.Addr().(*net.TCPAddr).Port)
+		case "MLST":
+			fi, err := os.Stat(f.local(arg))
+			if err != nil {
+				reply("550 not found")
+				continue
+			}
+			reply("250-Listing %s\r\n %s %s\r\n250 End", arg, mlsxFacts(fi), arg)
+		case "MLSD":
+			entries, err := os.ReadDir(f.local(arg))
+			if err != nil {
+				pasv.Close()
+				reply("550 not found")
+				continue
+			}
+			reply("150 listing")
+			dc, err := data()
+			if err != nil {
+				return
+			}
+			fmt.Fprint(dc, "type=cdir;modify=20200101000000; .\r\n")
+			for _, e := range entries {
+				if fi, err := e.Info(); err == nil {
+					fmt.Fprintf(dc, "%s %s\r\n", mlsxFacts(fi), e.Name())
+				}
+			}
+			dc.Close()
+			reply("226 done")
+		case "RETR":
+			src, err := os.Open(f.local(arg))
+			if err != nil {
+				pasv.Close()
+				reply("550 not found")
+				continue
+			}
+			reply("150 sending")
+			dc, err := data()
+			if err != nil {
+				src.Close()
+				return
+			}
+			io.Copy(dc, src)
+			src.Close()
+			dc.Close()
+			reply("226 done")
+		case "STOR":
+			dst, err := os.Create(f.local(arg))
+			if err != nil {
+				pasv.Close()
+				reply("553 cannot create")
+				continue
+			}
+			f.mu.Lock()
+			f.stores++
+			f.maxStore = max(f.maxStore, f.stores)
+			f.mu.Unlock()
+			reply("150 receiving")
+			dc, err := data()
+			if err == nil {
+				io.Copy(dst, dc)
+				dc.Close()
+			}
+			dst.Close()
+			time.Sleep(20 * time.Millisecond) // 讓並發上傳有機會重疊
+			f.mu.Lock()
+			f.stores--
+			f.mu.Unlock()
+			if err != nil {
+				return
+			}
+			reply("226 done")
+		case "MFMT":
+			ts, p, _ := strings.Cut(arg, " ")
+			mt, err := time.Parse("20060102150405", ts)
+			if err != nil || os.Chtimes(f.local(p), mt, mt) != nil {
+				reply("550 cannot set time")
+				continue
+			}
+			reply("213 Modify=%s; %s", ts, p)
+		case "RNFR":
+			renFrom = arg
+			reply("350 ready")
+		case "RNTO":
+			if os.Rename(f.local(renFrom), f.local(arg)) != nil {
+				reply("550 cannot rename")
+				continue
+			}
+			reply("250 ok")
+		case "DELE":
+			if fi, err := os.Stat(f.local(arg)); err != nil || fi.IsDir() {
+				reply("550 not a file")
+				continue
+			}
+			os.Remove(f.local(arg))
+			reply("250 ok")
+		case "RMD":
+			if os.Remove(f.local(arg)) != nil {
+				reply("550 cannot remove")
+				continue
+			}
+			reply("250 ok")
+		case "MKD":
+			if os.Mkdir(f.local(arg), 0755) != nil {
+				reply("550 cannot create")
+				continue
+			}
+			reply("257 created")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestFTPBackendRoundTrip(t *testing.T) {
+	f, addr := startFakeFTP(t)
+	u := &url.URL{Scheme: "ftp", User: url.UserPassword("deploy", "p@ss:/#word"), Host: addr, Path: "/site"}
+	b, err := openBackend(context.Background(), u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	testBackendRoundTrip(t, b)
+
+	// 支持 MFMT 時保留源文件的修改時間，且不留下臨時文件
+	mtime := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := b.Write(context.Background(), "index.html", strings.NewReader("<h1>hi</h1>"), fileEntry{Size: 11, ModTime: mtime}); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := b.Stat(context.Background(), "index.html"); err != nil || !e.ModTime.Equal(mtime) || e.Size != 11 {
+		t.Fatalf("Stat = %+v, %v，期望 11 字節、修改時間 %v", e, err, mtime)
+	}
+	entries, err := os.ReadDir(filepath.Join(f.root, "site"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("目標目錄 = %v, %v，期望只有 index.html", entries, err)
+	}
+}
+
+func TestFTPSBackendRoundTrip(t *testing.T) {
+	f, addr := startFakeFTP(t)
+	b, err := openBackend(context.Background(), "ftp://deploy:p%40ss%3A%2F%23word@"+addr+"/site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	// 測試證書自簽，換成信任它的 TLS 配置重新撥號
+	fb := b.(*ftpBackend)
+	fb.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(f.cert)
+	conf := &tls.Config{RootCAs: roots, ServerName: "127.0.0.1", ClientSessionCache: tls.NewLRUClientSessionCache(fb.conns)}
+	fb.dial = func(ctx context.Context) (*ftpConn, error) {
+		return dialFTP(ctx, addr, "deploy", "p@ss:/#word", conf)
+	}
+	testBackendRoundTrip(t, b)
+}
+
+// 支持 MFMT 時按修改時間精確比較，否則只能按目標是否不早於源文件判斷。
+func TestFTPSame(t *testing.T) {
+	mtime := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	for _, mfmt := range []bool{true, false} {
+		f, addr := startFakeFTP(t)
+		f.noMFMT = !mfmt
+		b, err := openBackend(context.Background(), "ftp://deploy:p%40ss%3A%2F%23word@"+addr+"/site")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer b.Close()
+		if err := b.Mkdir(context.Background(), ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Write(context.Background(), "index.html", strings.NewReader("<h1>v1</h1>"), fileEntry{Size: 11, ModTime: mtime}); err != nil {
+			t.Fatal(err)
+		}
+		d, err := b.Stat(context.Background(), "index.html")
+		if err != nil {
+			t.Fatal(err)
+		}
+		same := b.(contentComparer).Same
+		// 換成更舊的文件（如從歸檔恢復）：保留了源時間時能發現，否則按上傳時刻判斷會漏掉
+		for _, c := range []struct {
+			mtime time.Time
+			want  bool
+		}{{mtime, true}, {mtime.Add(-time.Hour), !mfmt}, {time.Now().Add(time.Hour), false}} {
+			s := fileEntry{Path: "index.html", Size: 11, ModTime: c.mtime}
+			if ok, _ := same(context.Background(), nil, s, d); ok != c.want {
+				t.Fatalf("支持 MFMT %v，源文件修改時間 %v: Same = %v，期望 %v", mfmt, c.mtime, ok, c.want)
+			}
+		}
+	}
+}
+
+// 並行寫入不超過 connections 條連接，多出的傳輸排隊等待。
+func TestFTPConnectionLimit(t *testing.T) {
+	f, addr := startFakeFTP(t)
+	b, err := openBackend(context.Background(), "ftp://deploy:p%40ss%3A%2F%23word@"+addr+"/site?connections=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if n := b.(transferLimiter).MaxTransfers(); n != 2 {
+		t.Fatalf("MaxTransfers = %d，期望 2", n)
+	}
+	if err := b.Mkdir(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, 6)
+	for i := range errs {
+		wg.Go(func() {
+			p := fmt.Sprintf("f%d.txt", i)
+			body := strings.Repeat("x", 1000*i+1)
+			errs[i] = b.Write(context.Background(), p, strings.NewReader(body), fileEntry{Path: p, Size: int64(len(body))})
+		})
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("寫入 f%d.txt: %v", i, err)
+		}
+	}
+	if f.maxStore > 2 {
+		t.Fatalf("同時有 %d 個上傳，超過連接數 2", f.maxStore)
+	}
+}
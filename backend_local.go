@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// --- 本地文件系統後端 ---
+// 普通路徑和 file:// 地址，網絡共享的盤符或 UNC 路徑也走這裡。
+
+type localBackend struct {
+	root string
+}
+
+// openLocal 打開本地路徑；指向 zip 或 tar.gz 文件時作為只讀的歸檔源。
+func openLocal(p string) (storageBackend, error) {
+	if isArchiveFile(p) {
+		return openArchive(p)
+	}
+	return localBackend{root: p}, nil
+}
+
+func isArchiveFile(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular() && archiveFormat(p) != ""
+}
+
+func (b localBackend) path(p string) string {
+	return filepath.Join(b.root, filepath.FromSlash(p))
+}
+
+func (b localBackend) List(ctx context.Context, dir string) ([]fileEntry, error) {
+	items, err := os.ReadDir(b.path(dir))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fileEntry, 0, len(items))
+	for _, item := range items {
+		info, err := item.Info()
+		if err != nil {
+			continue // 列出後被刪除
+		}
+		entries = append(entries, localEntry(joinPath(dir, item.Name()), info))
+	}
+	return entries, nil
+}
+
+func (b localBackend) Stat(ctx context.Context, p string) (fileEntry, error) {
+	info, err := os.Stat(b.path(p))
+	if err != nil {
+		return fileEntry{}, err
+	}
+	return localEntry(p, info), nil
+}
+
+func localEntry(p string, info os.FileInfo) fileEntry {
+	return fileEntry{Path: p, Size: info.Size(), ModTime: info.ModTime(), IsDir: info.IsDir()}
+}
+
+func (b localBackend) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	return os.Open(b.path(p))
+}
+
+func (b localBackend) Write(ctx context.Context, p string, r io.Reader, e fileEntry) error {
+	target := b.path(p)
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if e.ModTime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(target, e.ModTime, e.ModTime)
+}
+
+func (b localBackend) Remove(ctx context.Context, p string) error {
+	return os.Remove(b.path(p))
+}
+
+func (b localBackend) Mkdir(ctx context.Context, p string) error {
+	return os.MkdirAll(b.path(p), 0755)
+}
+
+func (b localBackend) Rename(ctx context.Context, from, to string) error {
+	return os.Rename(b.path(from), b.path(to))
+}
+
+func (b localBackend) Close() error { return nil }
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- S3 後端 ---
+// s3://訪問密鑰:私密密鑰@桶/前綴?endpoint=https://minio:9000&region=us-east-1
+//
+// 未指定 endpoint 時使用 AWS（按 region 選擇地址，桶名作為子域名）；指定 endpoint 時默認
+// 路徑風格，可用 path_style=false 關閉。密鑰也可以來自環境變量 AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY。
+// 對象存儲沒有目錄，列出時按 / 分隔模擬；修改時間不可寫，大小相同時按 ETag 與源文件的 MD5 比較，
+// 沒有 ETag 時比較上傳時記在 x-amz-meta-mtime 中的源文件修改時間。
+// Cache-Control 用 cache.<擴展名>=值 覆蓋默認規則，cache.*= 為其餘文件的默認值。
+
+const (
+	s3PartSize     = 16 << 20 // 超過此大小分片上傳，與 s3ETag 保持一致
+	s3DefaultCache = "public, max-age=86400"
+	s3SmallObject  = 1 << 20 // 不超過此大小的文件不佔用 s3Buffers
+	s3MaxBuffers   = 4
+)
+
+// s3Buffers 限制同時整塊讀進內存的大文件和分片，並行數調得再大，
+// 這部分內存也不超過 s3MaxBuffers×s3PartSize。
+var s3Buffers = make(chan struct{}, s3MaxBuffers)
+
+func acquireS3Buffer(ctx context.Context) (release func(), err error) {
+	select {
+	case s3Buffers <- struct{}{}:
+		return func() { <-s3Buffers }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// s3DocumentCache 是頁面類文件的默認 Cache-Control：每次訪問都回源確認，發布後立即生效。
+var s3DocumentCache = map[string]string{
+	".html": "no-cache", ".htm": "no-cache", ".xml": "no-cache", ".json": "no-cache",
+	".txt": "no-cache", ".webmanifest": "no-cache",
+}
+
+// s3ContentTypes 優先於系統的 MIME 表。Windows 的表來自註冊表，常把 .js 等標成 text/plain。
+var s3ContentTypes = map[string]string{
+	".html": "text/html; charset=utf-8", ".htm": "text/html; charset=utf-8",
+	".css": "text/css; charset=utf-8", ".js": "text/javascript; charset=utf-8", ".mjs": "text/javascript; charset=utf-8",
+	".json": "application/json", ".xml": "application/xml", ".txt": "text/plain; charset=utf-8",
+	".svg": "image/svg+xml", ".png": "image/png", ".jpg": "image/jpeg", ".jpeg": "image/jpeg",
+	".gif": "image/gif", ".webp": "image/webp", ".avif": "image/avif", ".ico": "image/x-icon",
+	".woff": "font/woff", ".woff2": "font/woff2", ".ttf": "font/ttf", ".otf": "font/otf",
+	".pdf": "application/pdf", ".wasm": "application/wasm", ".webmanifest": "application/manifest+json",
+	".mp4": "video/mp4", ".webm": "video/webm", ".mp3": "audio/mpeg", ".map": "application/json",
+}
+
+type s3Backend struct {
+	client *s3Client
+	prefix string            // 以 / 結尾，根目錄為 ""
+	cache  map[string]string // 擴展名（或 *）到 Cache-Control
+}
+
+func openS3(ctx context.Context, u *url.URL) (storageBackend, error) {
+	q := u.Query()
+	c := &s3Client{bucket: u.Host, region: q.Get("region"), http: &http.Client{}}
+	if c.bucket == "" {
+		return nil, errors.New("s3 地址缺少桶名")
+	}
+	if c.region == "" {
+		c.region = "us-east-1"
+	}
+	c.accessKey = u.User.Username()
+	c.secretKey, _ = u.User.Password()
+	if c.accessKey == "" {
+		c.accessKey, c.secretKey = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if c.accessKey == "" || c.secretKey == "" {
+		return nil, errors.New("s3 地址缺少訪問密鑰")
+	}
+	endpoint := q.Get("endpoint")
+	if endpoint == "" {
+		endpoint = "https://s3." + c.region + ".amazonaws.com"
+	} else {
+		c.pathStyle = q.Get("path_style") != "false"
+	}
+	e, err := url.Parse(endpoint)
+	if err != nil || e.Host == "" {
+		return nil, fmt.Errorf("s3 endpoint 格式錯誤: %s", endpoint)
+	}
+	c.endpoint = e
+
+	b := &s3Backend{client: c, cache: map[string]string{"*": s3DefaultCache}}
+	for ext, v := range s3DocumentCache {
+		b.cache[ext] = v
+	}
+	for k, v := range q {
+		if ext, ok := strings.CutPrefix(k, "cache."); ok && len(v) > 0 {
+			if ext != "*" {
+				ext = "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+			}
+			b.cache[ext] = v[0]
+		}
+	}
+	if p := strings.Trim(u.Path, "/"); p != "" {
+		b.prefix = p + "/"
+	}
+	return b, nil
+}
+
+func (b *s3Backend) key(p string) string { return b.prefix + p }
+
+// contentType 返回按擴展名推斷的 Content-Type。
+func contentType(p string) string {
+	ext := strings.ToLower(path.Ext(p))
+	if t, ok := s3ContentTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+func (b *s3Backend) cacheControl(p string) string {
+	if v, ok := b.cache[strings.ToLower(path.Ext(p))]; ok {
+		return v
+	}
+	return b.cache["*"]
+}
+
+type s3ListResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		LastModified time.Time
+		ETag         string
+		Size         int64
+	}
+	CommonPrefixes []struct {
+		Prefix string
+	}
+}
+
+// List 按 / 分隔列出一層，子前綴作為目錄。不存在的目錄返回空列表。
+func (b *s3Backend) List(ctx context.Context, dir string) ([]fileEntry, error) {
+	prefix := b.prefix
+	if dir != "" {
+		prefix += dir + "/"
+	}
+	var entries []fileEntry
+	q := url.Values{"list-type": {"2"}, "prefix": {prefix}, "delimiter": {"/"}}
+	for {
+		var res s3ListResult
+		if _, err := b.client.call(ctx, http.MethodGet, "", q, nil, nil, &res); err != nil {
+			return nil, err
+		}
+		for _, o := range res.Contents {
+			name := strings.TrimPrefix(o.Key, prefix)
+			if name == "" {
+				continue // 控制台創建的「目錄」佔位對象
+			}
+			entries = append(entries, fileEntry{
+				Path: joinPath(dir, name), Size: o.Size, ModTime: o.LastModified, Hash: strings.Trim(o.ETag, `"`),
+			})
+		}
+		for _, p := range res.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(p.Prefix, prefix), "/")
+			entries = append(entries, fileEntry{Path: joinPath(dir, name), IsDir: true})
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			return entries, nil
+		}
+		q.Set("continuation-token", res.NextContinuationToken)
+	}
+}
+
+// Stat 查詢對象；沒有同名對象但有以它為前綴的對象時視為目錄。
+func (b *s3Backend) Stat(ctx context.Context, p string) (fileEntry, error) {
+	if p != "" {
+		h, err := b.client.call(ctx, http.MethodHead, b.key(p), nil, nil, nil, nil)
+		if err == nil {
+			size, _ := strconv.ParseInt(h.Get("Content-Length"), 10, 64)
+			e := fileEntry{Path: p, Size: size, Hash: strings.Trim(h.Get("ETag"), `"`)}
+			e.ModTime, _ = http.ParseTime(h.Get("Last-Modified"))
+			if mtime, ok := s3MetaMtime(h); ok {
+				e.ModTime = mtime
+			}
+			return e, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return fileEntry{}, err
+		}
+	}
+	entries, err := b.List(ctx, p)
+	if err != nil {
+		return fileEntry{}, err
+	}
+	if len(entries) == 0 && p != "" {
+		return fileEntry{}, &s3Error{Status: http.StatusNotFound, Code: "NoSuchKey", Key: b.key(p)}
+	}
+	return fileEntry{Path: p, IsDir: true}, nil
+}
+
+func (b *s3Backend) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	resp, err := b.client.do(ctx, http.MethodGet, b.key(p), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Same 對象有 ETag 時總是讀源文件計算 ETag 比較，大小不變的修改、時鐘偏差都不會漏掉。
+// 沒有 ETag 時改比較上傳時記在對象元數據裡的源文件修改時間，LastModified 是上傳時間，不能代替；
+// 沒有記錄時重新上傳。
+func (b *s3Backend) Same(ctx context.Context, src storageBackend, s, d fileEntry) (bool, error) {
+	if s.Size != d.Size {
+		return false, nil
+	}
+	if d.Hash == "" {
+		h, err := b.client.call(ctx, http.MethodHead, b.key(d.Path), nil, nil, nil, nil)
+		if err != nil {
+			return false, err
+		}
+		mtime, ok := s3MetaMtime(h)
+		return ok && sameEntry(s, fileEntry{Size: d.Size, ModTime: mtime}), nil
+	}
+	r, err := src.Open(ctx, s.Path)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	etag, err := s3ETag(r, s.Size)
+	return etag == d.Hash, err
+}
+
+// s3MetaMtime 讀取上傳時記錄的源文件修改時間。
+func s3MetaMtime(h http.Header) (time.Time, bool) {
+	sec, err := strconv.ParseInt(h.Get("X-Amz-Meta-Mtime"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+func (b *s3Backend) headers(p string, e fileEntry) http.Header {
+	h := http.Header{}
+	h.Set("Content-Type", contentType(p))
+	if cc := b.cacheControl(p); cc != "" {
+		h.Set("Cache-Control", cc)
+	}
+	if !e.ModTime.IsZero() {
+		h.Set("X-Amz-Meta-Mtime", strconv.FormatInt(e.ModTime.Unix(), 10))
+	}
+	return h
+}
+
+// Write 小文件一次上傳，大文件分片上傳；失敗時放棄已上傳的分片。
+func (b *s3Backend) Write(ctx context.Context, p string, r io.Reader, e fileEntry) error {
+	key := b.key(p)
+	if e.Size > s3SmallObject {
+		release, err := acquireS3Buffer(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	if e.Size <= s3PartSize {
+		body, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		h := b.headers(p, e)
+		h.Set("Content-MD5", contentMD5(body))
+		_, err = b.client.call(ctx, http.MethodPut, key, nil, h, body, nil)
+		return err
+	}
+
+	var created struct{ UploadId string }
+	if _, err := b.client.call(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, b.headers(p, e), nil, &created); err != nil {
+		return err
+	}
+	err := b.uploadParts(ctx, key, created.UploadId, r)
+	if err != nil {
+		b.client.call(context.Background(), http.MethodDelete, key, url.Values{"uploadId": {created.UploadId}}, nil, nil, nil)
+	}
+	return err
+}
+
+type s3CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+func (b *s3Backend) uploadParts(ctx context.Context, key, uploadID string, r io.Reader) error {
+	var parts []s3CompletedPart
+	buf := make([]byte, s3PartSize)
+	for n := 1; ; n++ {
+		size, err := io.ReadFull(r, buf)
+		if size > 0 {
+			h := http.Header{}
+			h.Set("Content-MD5", contentMD5(buf[:size]))
+			q := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {uploadID}}
+			resp, perr := b.client.call(ctx, http.MethodPut, key, q, h, buf[:size], nil)
+			if perr != nil {
+				return perr
+			}
+			parts = append(parts, s3CompletedPart{PartNumber: n, ETag: resp.Get("ETag")})
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	_, err = b.client.call(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, nil, body, nil)
+	return err
+}
+
+func contentMD5(b []byte) string {
+	sum := md5.Sum(b)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Remove 刪除對象。目錄只是前綴，對應的鍵不存在，刪除也不會出錯。
+func (b *s3Backend) Remove(ctx context.Context, p string) error {
+	_, err := b.client.call(ctx, http.MethodDelete, b.key(p), nil, nil, nil, nil)
+	return err
+}
+
+func (b *s3Backend) Mkdir(ctx context.Context, p string) error { return nil }
+
+// Rename 在服務器端複製後刪除原對象。
+func (b *s3Backend) Rename(ctx context.Context, from, to string) error {
+	h := http.Header{}
+	h.Set("X-Amz-Copy-Source", "/"+b.client.bucket+"/"+s3Escape(b.key(from), true))
+	if _, err := b.client.call(ctx, http.MethodPut, b.key(to), nil, h, nil, nil); err != nil {
+		return err
+	}
+	return b.Remove(ctx, from)
+}
+
+func (b *s3Backend) Close() error {
+	b.client.http.CloseIdleConnections()
+	return nil
+}
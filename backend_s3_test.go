@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 是只有一個桶、路徑風格訪問的內存 S3，實現後端用到的那部分接口，
+// 並檢查每個請求的簽名頭和請求體摘要。
+type fakeS3 struct {
+	t      *testing.T
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string]fakeS3Object
+	uploads map[string]map[int][]byte
+	nextID  int
+}
+
+type fakeS3Object struct {
+	data     []byte
+	etag     string
+	header   http.Header
+	modified time.Time
+}
+
+func startFakeS3(t *testing.T, bucket string) (*fakeS3, string) {
+	f := &fakeS3{t: t, bucket: bucket, objects: map[string]fakeS3Object{}, uploads: map[string]map[int][]byte{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=") ||
+		r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		f.fail(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+	if md := r.Header.Get("Content-Md5"); md != "" && md != contentMD5(body) {
+		f.fail(w, http.StatusBadRequest, "BadDigest")
+		return
+	}
+	rest, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket)
+	if !ok {
+		f.fail(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	key := strings.TrimPrefix(rest, "/")
+	q := r.URL.Query()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, q.Get("prefix"), q.Get("delimiter"))
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		o, ok := f.objects[key]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		for k, v := range o.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("ETag", `"`+o.etag+`"`)
+		w.Header().Set("Last-Modified", o.modified.Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(o.data)))
+		if r.Method == http.MethodGet {
+			w.Write(o.data)
+		}
+	case r.Method == http.MethodPut && q.Has("partNumber"):
+		parts, ok := f.uploads[q.Get("uploadId")]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		parts[n] = body
+		s := md5.Sum(body)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(s[:])+`"`)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		src, _ := strings.CutPrefix(r.Header.Get("X-Amz-Copy-Source"), "/"+f.bucket+"/")
+		o, ok := f.objects[src]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		o.modified = time.Now()
+		f.objects[key] = o
+		fmt.Fprint(w, "<CopyObjectResult></CopyObjectResult>")
+	case r.Method == http.MethodPut:
+		s := md5.Sum(body)
+		f.put(key, body, hex.EncodeToString(s[:]), r.Header)
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		f.nextID++
+		id := fmt.Sprintf("upload-%d", f.nextID)
+		f.uploads[id] = map[int][]byte{}
+		f.objects[key+"\x00"+id] = fakeS3Object{header: r.Header.Clone()} // 創建時給出的元數據
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		id := q.Get("uploadId")
+		parts, ok := f.uploads[id]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var data, sums []byte
+		for n := 1; n <= len(parts); n++ {
+			data = append(data, parts[n]...)
+			s := md5.Sum(parts[n])
+			sums = append(sums, s[:]...)
+		}
+		s := md5.Sum(sums)
+		f.put(key, data, fmt.Sprintf("%s-%d", hex.EncodeToString(s[:]), len(parts)), f.objects[key+"\x00"+id].header)
+		delete(f.objects, key+"\x00"+id)
+		delete(f.uploads, id)
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		delete(f.objects, key+"\x00"+q.Get("uploadId"))
+		delete(f.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.fail(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) put(key string, data []byte, etag string, h http.Header) {
+	header := http.Header{}
+	for _, k := range []string{"Content-Type", "Cache-Control", "X-Amz-Meta-Mtime"} {
+		if v := h.Get(k); v != "" {
+			header.Set(k, v)
+		}
+	}
+	f.objects[key] = fakeS3Object{data: data, etag: etag, header: header, modified: time.Now()}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix, delimiter string) {
+	var res s3ListResult
+	var prefixes []string
+	for key, o := range f.objects {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok || strings.Contains(key, "\x00") {
+			continue
+		}
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			prefixes = append(prefixes, prefix+rest[:i+1])
+			continue
+		}
+		res.Contents = append(res.Contents, struct {
+			Key          string
+			LastModified time.Time
+			ETag         string
+			Size         int64
+		}{key, o.modified, `"` + o.etag + `"`, int64(len(o.data))})
+	}
+	slices.Sort(prefixes)
+	for _, p := range slices.Compact(prefixes) {
+		res.CommonPrefixes = append(res.CommonPrefixes, struct{ Prefix string }{p})
+	}
+	data, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"ListBucketResult"`
+		s3ListResult
+	}{s3ListResult: res})
+	if err != nil {
+		f.t.Error(err)
+	}
+	w.Write(data)
+}
+
+func (f *fakeS3) fail(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code></Error>", code)
+}
+
+func (f *fakeS3) object(key string) (fakeS3Object, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o, ok := f.objects[key]
+	return o, ok
+}
+
+func openFakeS3(t *testing.T) (*fakeS3, storageBackend) {
+	t.Helper()
+	f, endpoint := startFakeS3(t, "site")
+	b, err := openBackend(context.Background(), "s3://AKID:secret@site/public?endpoint="+endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return f, b
+}
+
+func TestS3BackendRoundTrip(t *testing.T) {
+	f, b := openFakeS3(t)
+	testBackendRoundTrip(t, b)
+
+	data := []byte("body{}")
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := b.Write(context.Background(), "css/site.css", bytes.NewReader(data), fileEntry{Size: int64(len(data)), ModTime: mtime}); err != nil {
+		t.Fatal(err)
+	}
+	o, ok := f.object("public/css/site.css")
+	if !ok {
+		t.Fatal("對象沒有寫在前綴下")
+	}
+	if got := o.header.Get("Content-Type"); got != "text/css; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := o.header.Get("Cache-Control"); got != s3DefaultCache {
+		t.Errorf("Cache-Control = %q", got)
+	}
+	e, err := b.Stat(context.Background(), "css/site.css")
+	if err != nil || !e.ModTime.Equal(mtime) {
+		t.Errorf("Stat = %+v, %v，期望修改時間 %v", e, err, mtime)
+	}
+	if e, err := b.Stat(context.Background(), "css"); err != nil || !e.IsDir {
+		t.Errorf("前綴 Stat = %+v, %v，期望目錄", e, err)
+	}
+}
+
+// 超過分片大小的文件分片上傳，得到的 ETag 與 s3ETag 按源文件算出的一致。
+func TestS3MultipartETag(t *testing.T) {
+	f, b := openFakeS3(t)
+	data := make([]byte, s3PartSize+s3PartSize/2)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	if err := b.Write(context.Background(), "video.mp4", bytes.NewReader(data), fileEntry{Size: int64(len(data))}); err != nil {
+		t.Fatal(err)
+	}
+	o, _ := f.object("public/video.mp4")
+	if !bytes.Equal(o.data, data) {
+		t.Fatalf("分片拼接後 %d 字節，與寫入的 %d 字節不同", len(o.data), len(data))
+	}
+	want, err := s3ETag(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.etag != want || !strings.HasSuffix(want, "-2") {
+		t.Fatalf("ETag = %s，s3ETag = %s", o.etag, want)
+	}
+	if len(s3Buffers) != 0 {
+		t.Fatalf("寫完後還佔著 %d 個緩衝", len(s3Buffers))
+	}
+}
+
+func TestS3Same(t *testing.T) {
+	_, b := openFakeS3(t)
+	dir := t.TempDir()
+	src, err := openBackend(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>v1</h1>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := b.Write(context.Background(), "index.html", strings.NewReader("<h1>v1</h1>"), fileEntry{Size: 11, ModTime: mtime}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := b.List(context.Background(), "")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("List = %v, %v", entries, err)
+	}
+	d := entries[0]
+	same := b.(contentComparer).Same
+
+	// 有 ETag 時總是按內容比較，即使對象比源文件新
+	s := fileEntry{Path: "index.html", Size: d.Size, ModTime: d.ModTime.Add(-time.Hour)}
+	if ok, err := same(context.Background(), src, s, d); !ok || err != nil {
+		t.Fatalf("內容相同 Same = %v, %v", ok, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>v2</h1>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := same(context.Background(), src, s, d); ok || err != nil {
+		t.Fatalf("大小不變但內容改過 Same = %v, %v", ok, err)
+	}
+
+	// 沒有 ETag 時比較元數據中記錄的源文件修改時間，不看上傳時間
+	d.Hash = ""
+	for _, c := range []struct {
+		mtime time.Time
+		want  bool
+	}{{mtime, true}, {mtime.Add(time.Hour), false}, {d.ModTime, false}} {
+		s := fileEntry{Path: "missing.html", Size: d.Size, ModTime: c.mtime}
+		if ok, err := same(context.Background(), src, s, d); ok != c.want || err != nil {
+			t.Fatalf("源文件修改時間 %v: Same = %v, %v，期望 %v", c.mtime, ok, err, c.want)
+		}
+	}
+}
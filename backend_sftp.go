@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// --- SFTP 後端 ---
+// sftp://用戶[:密碼]@主機[:端口]/路徑?key=私鑰&known_hosts=文件&mode=0644&dir_mode=0755
+//
+// 未指定 key 時依次嘗試 ~/.ssh 下的 id_ed25519、id_ecdsa、id_rsa；加密的私鑰用 passphrase 解鎖。
+// 主機密鑰必須出現在 known_hosts（默認 ~/.ssh/known_hosts）中，否則拒絕連接。
+// 文件先寫入同目錄的臨時文件再改名，網站不會讀到寫了一半的文件。
+// 路徑以 /~/ 開頭時相對於登錄用戶的主目錄。
+// 協議部分由 github.com/pkg/sftp 實現，多個傳輸共用一條 SSH 連接。
+
+const sftpDialTimeout = 30 * time.Second
+
+type sftpBackend struct {
+	conn    *ssh.Client
+	client  *sftp.Client
+	root    string
+	mode    os.FileMode // 文件權限，0 表示服務器默認
+	dirMode os.FileMode
+}
+
+func openSFTP(ctx context.Context, u *url.URL) (storageBackend, error) {
+	q := u.Query()
+	user := u.User.Username()
+	if user == "" {
+		return nil, errors.New("sftp 地址缺少用戶名")
+	}
+	mode, err := parseMode(q.Get("mode"))
+	if err != nil {
+		return nil, err
+	}
+	dirMode, err := parseMode(q.Get("dir_mode"))
+	if err != nil {
+		return nil, err
+	}
+
+	var auth []ssh.AuthMethod
+	if password, ok := u.User.Password(); ok {
+		auth = append(auth, ssh.Password(password))
+	}
+	signers, err := sftpSigners(q.Get("key"), q.Get("passphrase"))
+	if err != nil {
+		return nil, err
+	}
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("sftp 地址沒有密碼，也找不到可用的私鑰")
+	}
+	hostKeys, err := sftpHostKeyCallback(q.Get("known_hosts"))
+	if err != nil {
+		return nil, err
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+	d := net.Dialer{Timeout: sftpDialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	sc, chans, reqs, err := ssh.NewClientConn(nc, addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         sftpDialTimeout,
+	})
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	conn := ssh.NewClient(sc, chans, reqs)
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	root := u.Path
+	switch {
+	case root == "" || root == "/~":
+		root = "."
+	case len(root) > 3 && root[:3] == "/~/":
+		root = root[3:]
+	}
+	return &sftpBackend{conn: conn, client: client, root: root, mode: mode, dirMode: dirMode}, nil
+}
+
+// parseMode 解析八進制權限，如 0644；留空返回 0。
+func parseMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || m > 0777 {
+		return 0, fmt.Errorf("權限格式錯誤: %s", s)
+	}
+	return os.FileMode(m), nil
+}
+
+// sftpSigners 讀取指定的私鑰；未指定時收集 ~/.ssh 下存在且能解開的默認私鑰。
+func sftpSigners(key, passphrase string) ([]ssh.Signer, error) {
+	parse := func(file string) (ssh.Signer, error) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if passphrase != "" {
+			return ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+		}
+		return ssh.ParsePrivateKey(data)
+	}
+	if key != "" {
+		s, err := parse(key)
+		if err != nil {
+			return nil, fmt.Errorf("讀取私鑰 %s 失敗: %w", key, err)
+		}
+		return []ssh.Signer{s}, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil
+	}
+	var signers []ssh.Signer
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		if s, err := parse(filepath.Join(home, ".ssh", name)); err == nil {
+			signers = append(signers, s)
+		}
+	}
+	return signers, nil
+}
+
+func sftpHostKeyCallback(file string) (ssh.HostKeyCallback, error) {
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	cb, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("讀取 known_hosts 失敗: %w", err)
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := cb(hostname, remote, key)
+		var ke *knownhosts.KeyError
+		if errors.As(err, &ke) && len(ke.Want) == 0 {
+			return fmt.Errorf("主機 %s 不在 %s 中，請先用 ssh 連接一次確認指紋（%s）",
+				hostname, file, ssh.FingerprintSHA256(key))
+		}
+		return err
+	}, nil
+}
+
+func (b *sftpBackend) path(p string) string {
+	if p == "" {
+		return b.root
+	}
+	return path.Join(b.root, p)
+}
+
+// sftpError 給 pkg/sftp 返回的錯誤加上操作和路徑，errors.Is(err, fs.ErrNotExist) 仍然成立。
+func sftpError(op, p string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &fs.PathError{Op: "sftp " + op, Path: p, Err: err}
+}
+
+func (b *sftpBackend) List(ctx context.Context, dir string) ([]fileEntry, error) {
+	items, err := b.client.ReadDirContext(ctx, b.path(dir))
+	if err != nil {
+		return nil, sftpError("readdir", b.path(dir), err)
+	}
+	entries := make([]fileEntry, 0, len(items))
+	for _, fi := range items {
+		entries = append(entries, sftpEntry(joinPath(dir, fi.Name()), fi))
+	}
+	return entries, nil
+}
+
+func (b *sftpBackend) Stat(ctx context.Context, p string) (fileEntry, error) {
+	fi, err := b.client.Stat(b.path(p))
+	if err != nil {
+		return fileEntry{}, sftpError("stat", b.path(p), err)
+	}
+	return sftpEntry(p, fi), nil
+}
+
+func sftpEntry(p string, fi os.FileInfo) fileEntry {
+	return fileEntry{Path: p, Size: fi.Size(), ModTime: fi.ModTime(), IsDir: fi.IsDir()}
+}
+
+func (b *sftpBackend) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	f, err := b.client.Open(b.path(p))
+	if err != nil {
+		return nil, sftpError("open", b.path(p), err)
+	}
+	return f, nil
+}
+
+// Write 流水線化地寫入臨時文件（不等每個寫請求應答），設好權限和修改時間後再改名覆蓋目標。
+// 臨時文件出錯即刪除，並發寫入留下的空洞不會影響目標。
+func (b *sftpBackend) Write(ctx context.Context, p string, r io.Reader, e fileEntry) error {
+	target := b.path(p)
+	tmp := path.Join(path.Dir(target), ".hugo-sync-"+path.Base(target)+".tmp")
+	err := b.upload(ctx, tmp, r, e)
+	if err != nil {
+		b.client.Remove(tmp)
+		return err
+	}
+	return b.rename(tmp, target)
+}
+
+func (b *sftpBackend) upload(ctx context.Context, tmp string, r io.Reader, e fileEntry) error {
+	f, err := b.client.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return sftpError("create", tmp, err)
+	}
+	_, err = f.ReadFromWithConcurrency(&sftpCtxReader{ctx: ctx, r: r}, 0)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return sftpError("write", tmp, err)
+	}
+	if b.mode != 0 {
+		if err := b.client.Chmod(tmp, b.mode); err != nil {
+			return sftpError("chmod", tmp, err)
+		}
+	}
+	if !e.ModTime.IsZero() {
+		if err := b.client.Chtimes(tmp, e.ModTime, e.ModTime); err != nil {
+			return sftpError("chtimes", tmp, err)
+		}
+	}
+	return nil
+}
+
+// sftpCtxReader 讓上傳在 ctx 取消後停下，pkg/sftp 的寫入本身不接受 ctx。
+type sftpCtxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *sftpCtxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// rename 重命名並覆蓋已有的目標。服務器支持 posix-rename 擴展時一步完成，
+// 否則先刪除目標再按第 3 版的 RENAME 處理。
+func (b *sftpBackend) rename(from, to string) error {
+	if _, ok := b.client.HasExtension("posix-rename@openssh.com"); ok {
+		return sftpError("rename", from, b.client.PosixRename(from, to))
+	}
+	if err := b.client.Remove(to); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return sftpError("remove", to, err)
+	}
+	return sftpError("rename", from, b.client.Rename(from, to))
+}
+
+// Remove 刪除文件或空目錄，pkg/sftp 在按文件刪除失敗時會改用 rmdir。
+func (b *sftpBackend) Remove(ctx context.Context, p string) error {
+	return sftpError("remove", b.path(p), b.client.Remove(b.path(p)))
+}
+
+func (b *sftpBackend) Mkdir(ctx context.Context, p string) error {
+	full := b.path(p)
+	if fi, err := b.client.Stat(full); err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		return fmt.Errorf("sftp mkdir %s: 已存在同名文件", full)
+	}
+	// 逐級創建父目錄，一直到根目錄（parentDir 對根目錄返回它自己）
+	if parent := parentDir(p); parent != p {
+		if err := b.Mkdir(ctx, parent); err != nil {
+			return err
+		}
+	}
+	if err := b.client.Mkdir(full); err != nil {
+		// 並發創建時可能已被別的請求建好
+		if fi, serr := b.client.Stat(full); serr == nil && fi.IsDir() {
+			return nil
+		}
+		return sftpError("mkdir", full, err)
+	}
+	if b.dirMode != 0 {
+		return sftpError("chmod", full, b.client.Chmod(full, b.dirMode))
+	}
+	return nil
+}
+
+func (b *sftpBackend) Rename(ctx context.Context, from, to string) error {
+	return b.rename(b.path(from), b.path(to))
+}
+
+func (b *sftpBackend) Close() error {
+	b.client.Close()
+	return b.conn.Close()
+}
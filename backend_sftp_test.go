@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startSSHServer 在本機隨機端口啟動只提供 sftp 子系統的 SSH 服務器，
+// 返回地址和記錄了其主機密鑰的 known_hosts 文件。
+func startSSHServer(t *testing.T, user, password string) (addr, knownHosts string) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == user && string(pass) == password {
+				return nil, nil
+			}
+			return nil, os.ErrPermission
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(nc, config)
+		}
+	}()
+
+	addr = l.Addr().String()
+	knownHosts = filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, signer.PublicKey())
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return addr, knownHosts
+}
+
+func serveSSH(nc net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(nc, config)
+	if err != nil {
+		nc.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nch := range chans {
+		if nch.ChannelType() != "session" {
+			nch.Reject(ssh.UnknownChannelType, "只支持 session")
+			continue
+		}
+		ch, requests, err := nch.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				srv, err := sftp.NewServer(ch)
+				if err != nil {
+					ch.Close()
+					return
+				}
+				go func() {
+					srv.Serve()
+					ch.Close()
+				}()
+			}
+		}()
+	}
+}
+
+func TestSFTPBackendRoundTrip(t *testing.T) {
+	addr, knownHosts := startSSHServer(t, "deploy", "p@ss:/#word")
+	root := t.TempDir()
+	u := &url.URL{
+		Scheme:   "sftp",
+		User:     url.UserPassword("deploy", "p@ss:/#word"),
+		Host:     addr,
+		Path:     filepath.ToSlash(root) + "/site",
+		RawQuery: url.Values{"known_hosts": {knownHosts}}.Encode(),
+	}
+	b, err := openBackend(context.Background(), u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	// 根目錄本身不存在時 Mkdir 要逐級建出來
+	testBackendRoundTrip(t, b)
+	if _, err := os.Stat(filepath.Join(root, "site")); err != nil {
+		t.Fatalf("根目錄沒有建出來: %v", err)
+	}
+}
+
+// 多個傳輸共用一條連接並發寫入大文件，各自的請求和響應不能串或卡住。
+func TestSFTPConcurrentWrites(t *testing.T) {
+	addr, knownHosts := startSSHServer(t, "deploy", "secret")
+	root := t.TempDir()
+	b, err := openBackend(context.Background(), "sftp://deploy:secret@"+addr+filepath.ToSlash(root)+"?known_hosts="+url.QueryEscape(knownHosts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	data := bytes.Repeat([]byte{0x5a}, 4<<20)
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Go(func() {
+			p := fmt.Sprintf("f%d.bin", i)
+			errs[i] = b.Write(context.Background(), p, bytes.NewReader(data), fileEntry{Path: p, Size: int64(len(data))})
+		})
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("並發寫入卡住")
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("寫入 f%d.bin: %v", i, err)
+		}
+		if st, err := os.Stat(filepath.Join(root, fmt.Sprintf("f%d.bin", i))); err != nil || st.Size() != int64(len(data)) {
+			t.Fatalf("f%d.bin 不完整: %v", i, err)
+		}
+	}
+}
+
+func TestSFTPUnknownHostRejected(t *testing.T) {
+	addr, _ := startSSHServer(t, "deploy", "secret")
+	empty := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	u := "sftp://deploy:secret@" + addr + "/tmp?known_hosts=" + url.QueryEscape(empty)
+	if b, err := openBackend(context.Background(), u); err == nil {
+		b.Close()
+		t.Fatal("未知主機應拒絕連接")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testBackendRoundTrip 在空的後端根目錄上走一遍同步用到的所有操作。
+// 各協議的測試連上本地的替身服務器後調用它。
+func testBackendRoundTrip(t *testing.T, b storageBackend) {
+	t.Helper()
+	ctx := context.Background()
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	write := func(p string, data []byte) {
+		t.Helper()
+		if err := b.Write(ctx, p, bytes.NewReader(data), fileEntry{Path: p, Size: int64(len(data)), ModTime: mtime}); err != nil {
+			t.Fatalf("Write %s: %v", p, err)
+		}
+	}
+	read := func(p string) []byte {
+		t.Helper()
+		r, err := b.Open(ctx, p)
+		if err != nil {
+			t.Fatalf("Open %s: %v", p, err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("讀取 %s: %v", p, err)
+		}
+		return data
+	}
+	names := func(dir string) []string {
+		t.Helper()
+		entries, err := b.List(ctx, dir)
+		if err != nil {
+			t.Fatalf("List %q: %v", dir, err)
+		}
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.Path)
+		}
+		slices.Sort(out)
+		return out
+	}
+
+	if err := b.Mkdir(ctx, "a/b"); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	// 大於各協議的單塊大小，讀寫都要分多次
+	page := bytes.Repeat([]byte("<p>hugo-sync</p>\n"), 20000)
+	write("a/b/page.html", page)
+	write("a/b/old.html", []byte("old"))
+
+	e, err := b.Stat(ctx, "a/b/page.html")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if e.IsDir || e.Size != int64(len(page)) {
+		t.Fatalf("Stat = %+v，期望 %d 字節的文件", e, len(page))
+	}
+	if got := names("a/b"); !slices.Equal(got, []string{"a/b/old.html", "a/b/page.html"}) {
+		t.Fatalf("List a/b = %v", got)
+	}
+	if got := names(""); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("List 根目錄 = %v", got)
+	}
+	if got := read("a/b/page.html"); !bytes.Equal(got, page) {
+		t.Fatalf("讀回 %d 字節，內容與寫入的 %d 字節不同", len(got), len(page))
+	}
+
+	// 改名覆蓋已有的文件
+	if err := b.Rename(ctx, "a/b/page.html", "a/b/old.html"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if _, err := b.Stat(ctx, "a/b/page.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("改名後舊路徑 Stat = %v，期望不存在", err)
+	}
+	if got := read("a/b/old.html"); !bytes.Equal(got, page) {
+		t.Fatal("改名後目標內容不對")
+	}
+
+	if err := b.Remove(ctx, "a/b/old.html"); err != nil {
+		t.Fatalf("Remove 文件: %v", err)
+	}
+	if _, err := b.Stat(ctx, "a/b/old.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("刪除後 Stat = %v，期望不存在", err)
+	}
+	for _, dir := range []string{"a/b", "a"} {
+		if err := b.Remove(ctx, dir); err != nil {
+			t.Fatalf("Remove 目錄 %s: %v", dir, err)
+		}
+	}
+	if got := names(""); len(got) != 0 {
+		t.Fatalf("全部刪除後根目錄還有 %v", got)
+	}
+}
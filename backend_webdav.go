@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// --- WebDAV 後端 ---
+// webdav://用戶:密碼@主機[:端口]/路徑 走 HTTP，webdavs:// 走 HTTPS。
+//
+// 大多數服務器不允許寫 getlastmodified，上傳後的修改時間是上傳時刻，
+// 所以上傳後再用 PROPPATCH 把源文件的修改時間記在自定義屬性裡，比較時與源文件精確比較。
+// 不支持自定義屬性的服務器（如 nginx）只能退回「大小相同且目標不早於源文件」的判斷，
+// 大小不變、修改時間又不晚於上次上傳的改動（如從舊歸檔恢復的文件）會被漏掉，需要時請勾選強制覆蓋。
+// 支持 X-OC-Mtime 的服務器（Nextcloud 等）另外會保留 getlastmodified 為源時間。
+
+const (
+	davPropfind = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:s="` + davNS + `"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/><s:mtime/></d:prop></d:propfind>`
+	davProppatch = `<?xml version="1.0" encoding="utf-8"?>
+<d:propertyupdate xmlns:d="DAV:" xmlns:s="` + davNS + `"><d:set><d:prop><s:mtime>%d</s:mtime></d:prop></d:set></d:propertyupdate>`
+	davNS = "urn:hugo-sync"
+)
+
+type davBackend struct {
+	client  *davClient
+	noProps atomic.Bool // 服務器拒絕過自定義屬性，不再嘗試
+}
+
+func openWebDAV(ctx context.Context, u *url.URL) (storageBackend, error) {
+	base := *u
+	base.User = nil
+	base.Scheme = strings.Replace(u.Scheme, "webdav", "http", 1)
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	c := &davClient{base: &base, http: &http.Client{}}
+	c.user = u.User.Username()
+	c.pass, _ = u.User.Password()
+	b := &davBackend{client: c}
+	// 先用可重發的請求完成認證，之後流式上傳才能一次通過
+	if _, err := b.Stat(ctx, ""); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return b, nil
+}
+
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				Length   string `xml:"DAV: getcontentlength"`
+				Modified string `xml:"DAV: getlastmodified"`
+				Mtime    string `xml:"urn:hugo-sync mtime"` // 上傳時記下的源文件修改時間（Unix 秒）
+			} `xml:"DAV: prop"`
+			Status string `xml:"DAV: status"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// propfind 查詢 p 本身（depth 0）或其直接子條目（depth 1），返回以相對路徑為鍵的條目。
+func (b *davBackend) propfind(ctx context.Context, p string, depth string) (map[string]fileEntry, error) {
+	h := http.Header{}
+	h.Set("Depth", depth)
+	h.Set("Content-Type", "application/xml; charset=utf-8")
+	data, err := b.client.call(ctx, "PROPFIND", b.client.url(p, depth == "1"), h, []byte(davPropfind))
+	if err != nil {
+		return nil, err
+	}
+	var ms davMultistatus
+	if err := xml.Unmarshal(data, &ms); err != nil {
+		return nil, fmt.Errorf("webdav PROPFIND %s: 響應格式錯誤: %w", p, err)
+	}
+	entries := map[string]fileEntry{}
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+		rel, ok := strings.CutPrefix(strings.TrimSuffix(href.Path, "/"), strings.TrimSuffix(b.client.base.Path, "/"))
+		if !ok {
+			continue
+		}
+		rel = strings.Trim(rel, "/")
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			e := fileEntry{Path: rel, IsDir: ps.Prop.ResourceType.Collection != nil}
+			e.Size, _ = strconv.ParseInt(ps.Prop.Length, 10, 64)
+			e.ModTime, _ = http.ParseTime(ps.Prop.Modified)
+			if sec, err := strconv.ParseInt(ps.Prop.Mtime, 10, 64); err == nil {
+				e.ModTime, e.SourceTime = time.Unix(sec, 0), true
+			}
+			entries[rel] = e
+		}
+	}
+	return entries, nil
+}
+
+func (b *davBackend) List(ctx context.Context, dir string) ([]fileEntry, error) {
+	found, err := b.propfind(ctx, dir, "1")
+	if err != nil {
+		return nil, err
+	}
+	var entries []fileEntry
+	for rel, e := range found {
+		if rel != dir && parentDir(rel) == dir {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (b *davBackend) Stat(ctx context.Context, p string) (fileEntry, error) {
+	found, err := b.propfind(ctx, p, "0")
+	if err != nil {
+		return fileEntry{}, err
+	}
+	e, ok := found[p]
+	if !ok {
+		return fileEntry{}, &davError{Method: "PROPFIND", Path: p, Status: http.StatusNotFound}
+	}
+	return e, nil
+}
+
+func (b *davBackend) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	resp, err := b.client.do(ctx, http.MethodGet, b.client.url(p, false), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Same 目標記有源文件修改時間時精確比較；沒有記錄時只能看大小相同、且目標的修改時間不早於源文件。
+func (b *davBackend) Same(ctx context.Context, src storageBackend, s, d fileEntry) (bool, error) {
+	if d.SourceTime {
+		return sameEntry(s, d), nil
+	}
+	return s.Size == d.Size && d.ModTime.After(s.ModTime.Add(-time.Second)), nil
+}
+
+func (b *davBackend) Write(ctx context.Context, p string, r io.Reader, e fileEntry) error {
+	h := http.Header{}
+	h.Set("Content-Type", contentType(p))
+	if !e.ModTime.IsZero() {
+		h.Set("X-OC-Mtime", strconv.FormatInt(e.ModTime.Unix(), 10))
+	}
+	if _, err := b.client.call(ctx, http.MethodPut, b.client.url(p, false), h, &davStream{r: r, size: e.Size}); err != nil {
+		return err
+	}
+	if e.ModTime.IsZero() || b.noProps.Load() {
+		return nil
+	}
+	return b.setMtime(ctx, p, e.ModTime)
+}
+
+// setMtime 把源文件的修改時間記在自定義屬性中。服務器不支持時記下來，之後不再嘗試，
+// 上傳本身仍算成功；網絡錯誤等其他失敗照常返回。
+func (b *davBackend) setMtime(ctx context.Context, p string, mtime time.Time) error {
+	h := http.Header{}
+	h.Set("Content-Type", "application/xml; charset=utf-8")
+	data, err := b.client.call(ctx, "PROPPATCH", b.client.url(p, false), h, []byte(fmt.Sprintf(davProppatch, mtime.Unix())))
+	var de *davError
+	if errors.As(err, &de) {
+		b.noProps.Store(true)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var ms davMultistatus
+	if xml.Unmarshal(data, &ms) != nil || len(ms.Responses) == 0 {
+		b.noProps.Store(true)
+		return nil
+	}
+	for _, ps := range ms.Responses[0].Propstat {
+		if !strings.Contains(ps.Status, " 200 ") {
+			b.noProps.Store(true)
+		}
+	}
+	return nil
+}
+
+func (b *davBackend) Remove(ctx context.Context, p string) error {
+	_, err := b.client.call(ctx, http.MethodDelete, b.client.url(p, false), nil, nil)
+	return err
+}
+
+// Mkdir 逐級創建集合：405 表示已存在，409 表示父集合不存在。
+func (b *davBackend) Mkdir(ctx context.Context, p string) error {
+	_, err := b.client.call(ctx, "MKCOL", b.client.url(p, true), nil, nil)
+	var de *davError
+	if errors.As(err, &de) {
+		switch {
+		case de.Status == http.StatusMethodNotAllowed:
+			return nil
+		case de.Status == http.StatusConflict && p != "":
+			if err := b.Mkdir(ctx, parentDir(p)); err != nil {
+				return err
+			}
+			_, err = b.client.call(ctx, "MKCOL", b.client.url(p, true), nil, nil)
+		}
+	}
+	return err
+}
+
+func (b *davBackend) Rename(ctx context.Context, from, to string) error {
+	h := http.Header{}
+	h.Set("Destination", b.client.url(to, false).String())
+	h.Set("Overwrite", "T")
+	_, err := b.client.call(ctx, "MOVE", b.client.url(from, false), h, nil)
+	return err
+}
+
+func (b *davBackend) Close() error {
+	b.client.http.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// digestServer 在內存 WebDAV 前面加一層 Digest 認證（MD5、qop=auth）。
+// 換了 nonce 之後，帶舊 nonce 的請求收到 stale=true 的質詢。
+type digestServer struct {
+	user, pass string
+	h          http.Handler
+
+	mu      sync.Mutex
+	nonce   int
+	stale   int     // 發出的 stale 質詢數
+	lengths []int64 // 通過認證的 PUT 的 Content-Length
+}
+
+func (d *digestServer) rotate() {
+	d.mu.Lock()
+	d.nonce++
+	d.mu.Unlock()
+}
+
+func (d *digestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	nonce := fmt.Sprintf("nonce-%d", d.nonce)
+	d.mu.Unlock()
+	p := parseAuthParams(strings.TrimPrefix(r.Header.Get("Authorization"), "Digest "))
+	h := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := h(d.user + ":dav:" + d.pass)
+	ha2 := h(r.Method + ":" + p["uri"])
+	want := h(strings.Join([]string{ha1, p["nonce"], p["nc"], p["cnonce"], "auth", ha2}, ":"))
+	stale := false
+	if p["username"] == d.user && p["response"] == want && p["uri"] == r.URL.RequestURI() {
+		if p["nonce"] == nonce {
+			if r.Method == http.MethodPut {
+				d.mu.Lock()
+				d.lengths = append(d.lengths, r.ContentLength)
+				d.mu.Unlock()
+			}
+			d.h.ServeHTTP(w, r)
+			return
+		}
+		stale = true
+		d.mu.Lock()
+		d.stale++
+		d.mu.Unlock()
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="dav", qop="auth", nonce="%s", stale=%v`, nonce, stale))
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+// parseAuthParams 解析 Authorization 頭中 key="value" 形式的參數。
+func parseAuthParams(s string) map[string]string {
+	out := map[string]string{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		var val string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			val, s = rest[1:end+1], rest[end+2:]
+		} else {
+			val, s, _ = strings.Cut(rest, ",")
+		}
+		out[strings.TrimSpace(key)] = val
+	}
+	return out
+}
+
+func startDigestWebDAV(t *testing.T) (*digestServer, webdav.FileSystem, string) {
+	fs := webdav.NewMemFS()
+	d := &digestServer{user: "deploy", pass: "p@ss:/#word",
+		h: &webdav.Handler{Prefix: "/dav", FileSystem: fs, LockSystem: webdav.NewMemLS()}}
+	srv := httptest.NewServer(d)
+	t.Cleanup(srv.Close)
+	return d, fs, strings.Replace(srv.URL, "http://", "webdav://deploy:p%40ss%3A%2F%23word@", 1) + "/dav/site"
+}
+
+func TestWebDAVBackendRoundTrip(t *testing.T) {
+	d, _, endpoint := startDigestWebDAV(t)
+	b, err := openBackend(context.Background(), endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	testBackendRoundTrip(t, b)
+	for _, n := range d.lengths {
+		if n <= 0 {
+			t.Fatalf("PUT 沒有帶 Content-Length: %v", d.lengths)
+		}
+	}
+}
+
+// countingReader 記錄從源讀出的字節數，重發時若再讀一遍會多出來。
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// nonce 過期後的第一次上傳收到 stale 質詢，流式請求體還沒發出，換新 nonce 重發一次即可。
+func TestWebDAVStaleNonceReplaysStream(t *testing.T) {
+	d, fs, endpoint := startDigestWebDAV(t)
+	b, err := openBackend(context.Background(), endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if err := b.Mkdir(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	d.rotate()
+	data := bytes.Repeat([]byte("0123456789abcdef"), 64<<10)
+	src := &countingReader{r: bytes.NewReader(data)}
+	if err := b.Write(context.Background(), "big.bin", src, fileEntry{Path: "big.bin", Size: int64(len(data))}); err != nil {
+		t.Fatalf("stale 之後上傳: %v", err)
+	}
+	if d.stale != 1 {
+		t.Fatalf("stale 質詢 %d 次，期望 1 次", d.stale)
+	}
+	if src.n != len(data) {
+		t.Fatalf("源被讀了 %d 字節，期望只讀一遍 %d 字節", src.n, len(data))
+	}
+	f, err := fs.OpenFile(context.Background(), "/site/big.bin", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, _ := io.ReadAll(f)
+	if !bytes.Equal(got, data) {
+		t.Fatalf("服務器收到 %d 字節，內容與源不同", len(got))
+	}
+}
+
+// 上傳時把源文件的修改時間記在自定義屬性裡，之後精確比較；服務器不支持時退回按上傳時刻比較。
+func TestWebDAVSame(t *testing.T) {
+	mtime := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	for _, props := range []bool{true, false} {
+		d, _, endpoint := startDigestWebDAV(t)
+		if !props {
+			h := d.h
+			d.h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "PROPPATCH" {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+				h.ServeHTTP(w, r)
+			})
+		}
+		b, err := openBackend(context.Background(), endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer b.Close()
+		if err := b.Mkdir(context.Background(), ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Write(context.Background(), "index.html", strings.NewReader("<h1>v1</h1>"), fileEntry{Path: "index.html", Size: 11, ModTime: mtime}); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := b.List(context.Background(), "")
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("List = %v, %v", entries, err)
+		}
+		e := entries[0]
+		if e.SourceTime != props || (props && !e.ModTime.Equal(mtime)) {
+			t.Fatalf("支持自定義屬性 %v: 條目 = %+v", props, e)
+		}
+		same := b.(contentComparer).Same
+		// 換成更舊的文件（如從歸檔恢復）：記有源時間時能發現，否則按上傳時刻判斷會漏掉
+		for _, c := range []struct {
+			mtime time.Time
+			want  bool
+		}{{mtime, true}, {mtime.Add(-time.Hour), !props}, {time.Now().Add(time.Hour), false}} {
+			s := fileEntry{Path: "index.html", Size: 11, ModTime: c.mtime}
+			if ok, _ := same(context.Background(), nil, s, e); ok != c.want {
+				t.Fatalf("支持自定義屬性 %v，源文件修改時間 %v: Same = %v，期望 %v", props, c.mtime, ok, c.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- 命令行 ---
+// 不打開窗口直接執行與界面相同的任務流程，進度輸出到 stderr。
+//
+//	hugo-sync run --profile blog --groups 2,3 --tasks posts
+//	hugo-sync plan | validate | list | report | watch | daemon | serve
+
+// 進程退出碼
+const (
+	exitOK        = 0
+	exitFailed    = 1
+	exitUsage     = 2
+	exitCancelled = 130
+)
+
+var cliCommands = map[string]func(args []string) int{
+	"run":      cliRun,
+	"plan":     cliPlan,
+	"validate": cliValidate,
+	"list":     cliList,
+	"report":   cliReport,
+	"watch":    cliWatch,
+	"daemon":   cliDaemon,
+	"serve":    cliServe,
+}
+
+// printEvents 訂閱事件並打印到 stderr，返回的函數取消訂閱並等剩餘事件打印完。
+// 逐個文件的事件不打印，進度每兩秒最多打印一行。
+func printEvents() (stop func()) {
+	ch, unsubscribe := events.Subscribe(1024)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var last time.Time
+		for e := range ch {
+			switch e := e.(type) {
+			case Progress:
+				if time.Since(last) < 2*time.Second || e.FilesTotal == 0 {
+					continue
+				}
+				last = time.Now()
+				fmt.Fprintf(os.Stderr, "進度: [%s] %s\n", e.Task, e)
+			case FileCopied, FileDeleted:
+			default:
+				fmt.Fprintln(os.Stderr, redactSecrets(e.Text()))
+			}
+		}
+	}()
+	return func() {
+		unsubscribe()
+		<-done
+	}
+}
+
+func runCLI(args []string) int {
+	// 兼容舊的 `hugo-sync -tasks a,b` 寫法
+	if strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		args = append([]string{"run"}, args...)
+	}
+	cmd, ok := cliCommands[args[0]]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "用法: hugo-sync <run|plan|validate|list|report|watch|daemon|serve> [--profile 名稱] [--groups 1,2] [--tasks a,b]")
+		return exitUsage
+	}
+	return cmd(args[1:])
+}
+
+// cliSelection 是 run/plan/validate 共用的選項。
+type cliSelection struct {
+	profile   string
+	groups    string
+	tasks     string
+	force     bool
+	reportDir string
+	junit     bool
+}
+
+func (sel *cliSelection) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&sel.profile, "profile", defaultProfile, "配置名稱")
+	fs.StringVar(&sel.groups, "groups", "", "執行順序，默認使用配置中的分組順序")
+	fs.StringVar(&sel.tasks, "tasks", "", "只執行指定名稱的任務，逗號分隔")
+	fs.BoolVar(&sel.force, "force", false, "強制覆蓋模式")
+	return fs
+}
+
+// load 讀取配置並解析分組和任務名，出錯時已把原因打印到 stderr。
+func (sel *cliSelection) load() (conf Config, order string, names []string, ok bool) {
+	conf, err := loadProfile(sel.profile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "讀取配置失敗:", err)
+		return conf, "", nil, false
+	}
+	order = formatGroupOrder(groupOrderOf(conf))
+	if sel.groups != "" {
+		order = sel.groups
+	}
+	for _, n := range strings.Split(sel.tasks, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	if dup := duplicateTaskNames(conf.Tasks); len(dup) > 0 && len(names) > 0 {
+		fmt.Fprintln(os.Stderr, "任務名重複，無法按名稱選擇:", strings.Join(dup, ", "))
+		return conf, "", nil, false
+	}
+	for _, n := range names {
+		if !hasTaskNamed(conf.Tasks, n) {
+			fmt.Fprintln(os.Stderr, "找不到任務:", n)
+			return conf, "", nil, false
+		}
+	}
+	return conf, order, names, true
+}
+
+func cliRun(args []string) int {
+	var sel cliSelection
+	fs := sel.flags("run")
+	fs.StringVar(&sel.reportDir, "report-dir", "", "運行報告目錄，默認使用配置中的 report_dir")
+	fs.BoolVar(&sel.junit, "junit", false, "同時輸出 JUnit XML 報告")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	conf, order, names, ok := sel.load()
+	if !ok {
+		return exitUsage
+	}
+	if sel.reportDir != "" {
+		conf.ReportDir = sel.reportDir
+	}
+	conf.JUnitReport = conf.JUnitReport || sel.junit
+
+	stopPrint := printEvents()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	res := executeRun(ctx, "命令行", sel.profile, conf, order, names, sel.force || conf.ForceCopy)
+	stopPrint()
+	return res.ExitCode()
+}
+
+// cliWatch 監視源目錄並在變化後自動運行，直到 Ctrl+C。
+func cliWatch(args []string) int {
+	var sel cliSelection
+	if err := sel.flags("watch").Parse(args); err != nil {
+		return exitUsage
+	}
+	conf, order, _, ok := sel.load()
+	if !ok {
+		return exitUsage
+	}
+
+	defer printEvents()()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err := watchAndRun(ctx, conf, func(ctx context.Context, names []string) runResult {
+		return executeRun(ctx, "監視觸發", sel.profile, conf, order, names, sel.force || conf.ForceCopy)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+// cliDaemon 按配置中的 cron 計劃觸發運行，直到 Ctrl+C。
+// 不指定 --profile 時調度所有配置。
+func cliDaemon(args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	profile := fs.String("profile", "", "只調度該配置；留空則調度所有配置")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	profiles := listProfiles()
+	if *profile != "" {
+		profiles = []string{*profile}
+	}
+	confs := map[string]Config{}
+	for _, p := range profiles {
+		conf, err := loadProfile(p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "讀取配置失敗:", p, err)
+			return exitUsage
+		}
+		if len(conf.Schedules) > 0 {
+			confs[p] = conf
+		}
+	}
+	if len(confs) == 0 {
+		fmt.Fprintln(os.Stderr, "沒有配置計劃")
+		return exitUsage
+	}
+
+	defer printEvents()()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// 各配置的計劃共用一個隊列，不同配置的運行也不會交錯
+	runs := newRunQueue(func(ctx context.Context, r *runRequest) runResult {
+		return executeRun(ctx, r.Title, r.Profile, r.Config, r.Order, r.Names, r.Config.ForceCopy)
+	}, nil)
+	var wg sync.WaitGroup
+	for p, conf := range confs {
+		wg.Go(func() {
+			runScheduler(ctx, p, conf, func(ctx context.Context, s Schedule) runResult {
+				return <-runs.Submit(ctx, &runRequest{
+					Title: "計劃 " + s.Name, Profile: p, Config: conf, Order: s.order(conf), Names: s.taskNames(),
+				})
+			}, func(name string, at time.Time) {
+				if !at.IsZero() {
+					postStatus(fmt.Sprintf("[%s] 下次計劃: %s %s", p, name, at.Format("2006-01-02 15:04")))
+				}
+			})
+		})
+	}
+	wg.Wait()
+	return exitOK
+}
+
+// cliServe 只運行 HTTP 控制接口，直到 Ctrl+C。接口配置取自默認配置的 api 項。
+func cliServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "", "監聽地址，默認使用配置中的 api.addr")
+	token := fs.String("token", "", "接口令牌，默認使用配置中的 api.token")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	conf, err := loadProfile(defaultProfile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "讀取配置失敗:", err)
+		return exitUsage
+	}
+	cfg := conf.API
+	if *addr != "" {
+		cfg.Addr = *addr
+	}
+	if *token != "" {
+		cfg.Token = *token
+	}
+
+	defer printEvents()()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	runs := newRunQueue(func(ctx context.Context, r *runRequest) runResult {
+		return executeRun(ctx, r.Title, r.Profile, r.Config, r.Order, r.Names, r.Config.ForceCopy)
+	}, nil)
+	if err := serveAPI(ctx, cfg, loadProfile, runs.Submit); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+func cliPlan(args []string) int {
+	var sel cliSelection
+	if err := sel.flags("plan").Parse(args); err != nil {
+		return exitUsage
+	}
+	conf, order, names, ok := sel.load()
+	if !ok {
+		return exitUsage
+	}
+	printPlan(os.Stdout, selectTasks(conf.Tasks, order, names), sel.force || conf.ForceCopy)
+	return exitOK
+}
+
+func printPlan(w io.Writer, tasks []TaskItem, force bool) {
+	if len(tasks) == 0 {
+		fmt.Fprintln(w, "沒有要執行的任務")
+		return
+	}
+	group := -1
+	for i, t := range tasks {
+		if i == 0 || t.GroupID != group {
+			group = t.GroupID
+			fmt.Fprintf(w, "分組 %d\n", group)
+		}
+		fmt.Fprintf(w, "  %d. %s\n", i+1, describeTask(t, force))
+	}
+}
+
+// describeTask 用一行文字描述任務要做的事，密鑰保持佔位符形式。
+func describeTask(t TaskItem, force bool) string {
+	switch t.Type {
+	case TaskSync:
+		mode := ""
+		if force {
+			mode += "（強制覆蓋）"
+		}
+		if t.Mirror {
+			mode += "（鏡像刪除）"
+		}
+		return fmt.Sprintf("[%s] 同步 %s -> %s%s", t.Name, t.Src, t.Dst, mode)
+	case TaskCmd:
+		return fmt.Sprintf("[%s] 在 %s 執行 %s", t.Name, t.Root, t.Cmd)
+	case TaskGit:
+		return fmt.Sprintf("[%s] 發布 %s -> %s 的 %s 分支", t.Name, t.Src, t.Dst, cmp.Or(t.Branch, defaultGitBranch))
+	case TaskArchive:
+		keep := ""
+		if t.Keep > 0 {
+			keep = fmt.Sprintf("（保留最近 %d 個）", t.Keep)
+		}
+		return fmt.Sprintf("[%s] 打包 %s -> %s/%s%s", t.Name, t.Src, t.Dst, cmp.Or(t.Archive, defaultArchiveName), keep)
+	}
+	return fmt.Sprintf("[%s] 未知類型 %s", t.Name, t.Type)
+}
+
+func cliValidate(args []string) int {
+	var sel cliSelection
+	if err := sel.flags("validate").Parse(args); err != nil {
+		return exitUsage
+	}
+	conf, order, names, ok := sel.load()
+	if !ok {
+		return exitUsage
+	}
+	problems := validateConfig(conf, selectTasks(conf.Tasks, order, names))
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, redactSecrets(p))
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "發現 %d 個問題\n", len(problems))
+		return exitFailed
+	}
+	fmt.Fprintln(os.Stderr, "配置有效")
+	return exitOK
+}
+
+// validateConfig 檢查配置本身以及本次要執行的任務，返回人類可讀的問題列表。
+// 密鑰庫未解鎖時跳過依賴密鑰的路徑檢查。
+func validateConfig(conf Config, selected []TaskItem) []string {
+	var problems []string
+	for _, n := range duplicateTaskNames(conf.Tasks) {
+		problems = append(problems, fmt.Sprintf("[%s] 任務名重複", n))
+	}
+	for _, s := range conf.Schedules {
+		if _, err := parseCron(s.Cron); err != nil {
+			problems = append(problems, fmt.Sprintf("計劃 %s: %v", s.Name, err))
+		}
+		for _, n := range s.taskNames() {
+			if !hasTaskNamed(conf.Tasks, n) {
+				problems = append(problems, fmt.Sprintf("計劃 %s: 找不到任務 %s", s.Name, n))
+			}
+		}
+	}
+	for _, t := range selected {
+		expanded, err := expandTask(t)
+		if err != nil && !errors.Is(err, errSecretsLocked) {
+			problems = append(problems, fmt.Sprintf("[%s] %v", t.Name, err))
+			continue
+		}
+		if t.Workers < 0 {
+			problems = append(problems, fmt.Sprintf("[%s] workers 不能為負數", t.Name))
+		}
+		switch t.Type {
+		case TaskSync:
+			if t.Src == "" || t.Dst == "" {
+				problems = append(problems, fmt.Sprintf("[%s] 源目錄或目標目錄為空", t.Name))
+			} else if err == nil {
+				problems = append(problems, checkEndpoints(t.Name, t.Src, expanded.Src, expanded.Dst)...)
+			}
+		case TaskCmd:
+			if strings.TrimSpace(t.Cmd) == "" {
+				problems = append(problems, fmt.Sprintf("[%s] 命令為空", t.Name))
+			}
+			if t.Root != "" && err == nil && !isDir(expanded.Root) {
+				problems = append(problems, fmt.Sprintf("[%s] 根目錄不存在: %s", t.Name, t.Root))
+			}
+		case TaskGit:
+			if t.Src == "" || t.Dst == "" {
+				problems = append(problems, fmt.Sprintf("[%s] 源目錄或遠程倉庫為空", t.Name))
+			} else if _, lerr := exec.LookPath("git"); lerr != nil {
+				problems = append(problems, fmt.Sprintf("[%s] 找不到 git 命令", t.Name))
+			} else if err == nil {
+				problems = append(problems, checkEndpoints(t.Name, t.Src, expanded.Src, gitWorktree(expanded))...)
+			}
+		case TaskArchive:
+			if t.Src == "" || t.Dst == "" {
+				problems = append(problems, fmt.Sprintf("[%s] 源目錄或輸出目錄為空", t.Name))
+			} else if err == nil {
+				problems = append(problems, checkEndpoints(t.Name, t.Src, expanded.Src, expanded.Dst)...)
+			}
+			if archiveFormat(cmp.Or(t.Archive, defaultArchiveName)) == "" {
+				problems = append(problems, fmt.Sprintf("[%s] 歸檔文件名須以 .zip、.tar.gz 或 .tgz 結尾: %s", t.Name, t.Archive))
+			}
+			if t.Keep < 0 {
+				problems = append(problems, fmt.Sprintf("[%s] keep 不能為負數", t.Name))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("[%s] 未知任務類型: %s", t.Name, t.Type))
+		}
+	}
+	return problems
+}
+
+// checkEndpoints 檢查同步任務兩端的地址，本地源還需確認目錄存在。遠程端點不在這裡連接。
+func checkEndpoints(name, rawSrc, src, dst string) []string {
+	var problems []string
+	if _, err := parseEndpoint(src); err != nil {
+		problems = append(problems, fmt.Sprintf("[%s] 源: %v", name, err))
+	} else if dir, ok := localPath(src); ok && !isDir(dir) && !isArchiveFile(dir) {
+		problems = append(problems, fmt.Sprintf("[%s] 源目錄不存在: %s", name, rawSrc))
+	}
+	if _, err := parseEndpoint(dst); err != nil {
+		problems = append(problems, fmt.Sprintf("[%s] 目標: %v", name, err))
+	}
+	return problems
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func cliList(args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	profile := fs.String("profile", "", "列出該配置中的任務；留空則列出所有配置")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *profile == "" {
+		for _, p := range listProfiles() {
+			fmt.Println(p)
+		}
+		return exitOK
+	}
+	conf, err := loadProfile(*profile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "讀取配置失敗:", err)
+		return exitUsage
+	}
+	for _, g := range groupOrderOf(conf) {
+		fmt.Printf("分組 %d\n", g)
+		for _, t := range conf.Tasks {
+			if t.GroupID != g {
+				continue
+			}
+			state := " "
+			if t.Enabled {
+				state = "✓"
+			}
+			fmt.Printf("  %s %-20s %s\n", state, t.Name, t.Type)
+		}
+	}
+	return exitOK
+}
+
+// cliReport 打印某份運行報告，默認為該配置報告目錄中最新的一份。
+func cliReport(args []string) int {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	profile := fs.String("profile", defaultProfile, "從該配置的報告目錄中查找")
+	raw := fs.Bool("json", false, "原樣輸出 JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	path := fs.Arg(0)
+	if path == "" {
+		conf, err := loadProfile(*profile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "讀取配置失敗:", err)
+			return exitUsage
+		}
+		if path, err = latestReport(reportDir(conf)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailed
+		}
+	}
+	if *raw {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailed
+		}
+		os.Stdout.Write(data)
+		fmt.Println()
+		return exitOK
+	}
+	rep, err := readReport(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+	printReport(os.Stdout, rep)
+	return exitOK
+}
+
+func hasTaskNamed(tasks []TaskItem, name string) bool {
+	for _, t := range tasks {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
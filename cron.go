@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- cron 表達式 ---
+// 支持標準五段式「分 時 日 月 周」，每段可用 *、列表、範圍和步長（如 */15、1-5、0,30），
+// 以及 @hourly / @daily / @weekly / @monthly / @yearly 簡寫。
+// 日和周同時被限定（都不以 * 開頭）時，兩者滿足其一即觸發，與傳統 cron 一致。
+// 時間按所在時區的掛鐘讀數匹配，夏令時切換當天不會漏跑或重跑。
+
+type cronExpr struct {
+	minute, hour, dom, month, dow uint64 // 每個比特代表一個允許的取值
+	domAny, dowAny                bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+func parseCron(spec string) (cronExpr, error) {
+	spec = strings.TrimSpace(spec)
+	if m, ok := cronMacros[spec]; ok {
+		spec = m
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return cronExpr{}, fmt.Errorf("cron 表達式需要 5 段: %q", spec)
+	}
+	var e cronExpr
+	var err error
+	if e.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return e, err
+	}
+	if e.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return e, err
+	}
+	if e.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return e, err
+	}
+	if e.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return e, err
+	}
+	if e.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return e, err
+	}
+	// 周日可寫作 0 或 7
+	if e.dow&(1<<7) != 0 {
+		e.dow |= 1
+	}
+	// 與傳統 cron 一致，以 * 開頭的段（含 */2）不算限定
+	e.domAny = strings.HasPrefix(fields[2], "*")
+	e.dowAny = strings.HasPrefix(fields[4], "*")
+	return e, nil
+}
+
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("無效的步長: %q", part)
+			}
+			step, part = n, part[:i]
+		}
+		from, to := lo, hi
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			a, b, _ := strings.Cut(part, "-")
+			var err1, err2 error
+			from, err1 = strconv.Atoi(a)
+			to, err2 = strconv.Atoi(b)
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("無效的範圍: %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("無效的取值: %q", part)
+			}
+			from, to = n, n
+			if step > 1 {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return 0, fmt.Errorf("取值超出範圍 %d-%d: %q", lo, hi, field)
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func (e cronExpr) dayMatches(t time.Time) bool {
+	dom := e.dom&(1<<t.Day()) != 0
+	dow := e.dow&(1<<int(t.Weekday())) != 0
+	// 任一段以 * 開頭時兩段都要滿足（*/2 的步長仍然生效），都被限定時滿足其一即可
+	if e.domAny || e.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next 返回嚴格晚於 t 的下一個觸發時間（精確到分鐘），五年內沒有則返回零值。
+// 按 t 所在時區的掛鐘時間匹配：夏令時跳過的時間在跳躍的時刻觸發，撥回時重複的時間只觸發一次。
+func (e cronExpr) Next(t time.Time) time.Time {
+	// 在沒有夏令時的 UTC 上按掛鐘時間逐級查找，找到後再換算回 t 的時區
+	w := wallClock(t).Truncate(time.Minute).Add(time.Minute)
+	limit := w.AddDate(5, 0, 0)
+	for w.Before(limit) {
+		if e.month&(1<<int(w.Month())) == 0 {
+			w = time.Date(w.Year(), w.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !e.dayMatches(w) {
+			w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if e.hour&(1<<w.Hour()) == 0 {
+			w = time.Date(w.Year(), w.Month(), w.Day(), w.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if e.minute&(1<<w.Minute()) == 0 {
+			w = w.Add(time.Minute)
+			continue
+		}
+		// 重複時段的第一次已經過去時，這個掛鐘時間不再觸發
+		if next := wallTime(w, t.Location()); next.After(t) {
+			return next
+		}
+		w = w.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+// wallClock 返回與 t 掛鐘讀數相同的 UTC 時間。
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// wallTime 返回 loc 中掛鐘讀數為 w 的時刻。夏令時跳過的讀數取跳躍的時刻，出現兩次的取第一次。
+// time.Date 對這兩種情況的結果沒有保證，這裡按時區邊界自行換算。
+func wallTime(w time.Time, loc *time.Location) time.Time {
+	t := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), 0, 0, loc)
+	start, end := t.ZoneBounds()
+	switch got := wallClock(t); {
+	case got.Before(w) && !end.IsZero():
+		return end
+	case got.After(w) && !start.IsZero():
+		return start
+	}
+	if !start.IsZero() {
+		_, off := start.Add(-time.Second).Zone()
+		if first := time.Unix(w.Unix()-int64(off), 0).In(loc); first.Before(start) && wallClock(first).Equal(w) {
+			return first
+		}
+	}
+	return t
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+	_ "time/tzdata" // 測試機器上不一定有時區數據庫
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{
+		"* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"*/0 * * * *", "5-1 * * * *", "a * * * *", "1-x * * * *", "@every 5m",
+	} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("parseCron(%q) 應報錯", spec)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	utc := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04:05", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	cases := []struct {
+		name, spec, from, want string
+	}{
+		{"每分鐘，秒數捨去", "* * * * *", "2024-03-01 10:00:30", "2024-03-01 10:01:00"},
+		{"嚴格晚於起點", "0 10 * * *", "2024-03-01 10:00:00", "2024-03-02 10:00:00"},
+		{"步長", "*/15 * * * *", "2024-03-01 10:07:00", "2024-03-01 10:15:00"},
+		{"帶起點的步長", "5/20 * * * *", "2024-03-01 10:26:00", "2024-03-01 10:45:00"},
+		{"範圍內的步長", "10-40/15 * * * *", "2024-03-01 10:41:00", "2024-03-01 11:10:00"},
+		{"列表與範圍", "0,30 9-17 * * 1-5", "2024-03-08 17:30:00", "2024-03-11 09:00:00"},
+		{"跨月：四月沒有 31 日", "0 0 31 * *", "2024-04-15 00:00:00", "2024-05-31 00:00:00"},
+		{"跨年", "@monthly", "2024-12-15 08:00:00", "2025-01-01 00:00:00"},
+		{"閏日", "0 0 29 2 *", "2023-03-01 00:00:00", "2024-02-29 00:00:00"},
+		{"周日寫作 7", "0 0 * * 7", "2024-03-01 00:00:00", "2024-03-03 00:00:00"},
+		{"日和周都限定時滿足其一", "0 12 1 * 1", "2024-03-02 00:00:00", "2024-03-04 12:00:00"},
+		{"日和周都限定時滿足其一（日）", "0 12 1 * 1", "2024-03-25 13:00:00", "2024-04-01 12:00:00"},
+		{"日以 * 開頭時兩者都要滿足", "0 12 */2 * 1", "2024-03-01 00:00:00", "2024-03-11 12:00:00"},
+		{"周以 * 開頭時兩者都要滿足", "0 12 13 * */7", "2024-03-01 00:00:00", "2024-10-13 12:00:00"},
+		{"年末最後一分鐘", "59 23 31 12 *", "2024-12-31 23:59:00", "2025-12-31 23:59:00"},
+		{"永遠不會觸發", "0 0 30 2 *", "2024-01-01 00:00:00", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e, err := parseCron(c.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want time.Time
+			if c.want != "" {
+				want = utc(c.want)
+			}
+			if got := e.Next(utc(c.from)); !got.Equal(want) {
+				t.Fatalf("%q 從 %s 起下一次為 %v，期望 %v", c.spec, c.from, got, want)
+			}
+		})
+	}
+}
+
+// 夏令時切換當天：跳過的時間在跳躍時觸發一次，重複的時間只在第一次經過時觸發。
+func TestCronNextDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(loc *time.Location, y int, mo time.Month, d, h, mi int) time.Time {
+		return time.Date(y, mo, d, h, mi, 0, 0, loc)
+	}
+	cases := []struct {
+		name string
+		spec string
+		from time.Time
+		want []time.Time // 從 from 起連續的觸發時間
+	}{
+		{"紐約春季跳過 2 點", "30 2 * * *", at(ny, 2024, 3, 9, 12, 0), []time.Time{
+			time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), at(ny, 2024, 3, 11, 2, 30),
+		}},
+		{"跳過的一小時內只補一次", "*/20 * * * *", at(ny, 2024, 3, 10, 1, 30), []time.Time{
+			at(ny, 2024, 3, 10, 1, 40), time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), at(ny, 2024, 3, 10, 3, 20),
+		}},
+		{"紐約秋季重複 1 點", "30 1 * * *", at(ny, 2024, 11, 2, 12, 0), []time.Time{
+			time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC), at(ny, 2024, 11, 4, 1, 30),
+		}},
+		{"重複的一小時不再按小時觸發", "0 * * * *", at(ny, 2024, 11, 3, 0, 30), []time.Time{
+			time.Date(2024, 11, 3, 5, 0, 0, 0, time.UTC), time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC),
+		}},
+		{"柏林春季跳過 2 點", "30 2 * * *", at(berlin, 2024, 3, 30, 12, 0), []time.Time{
+			time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC), at(berlin, 2024, 4, 1, 2, 30),
+		}},
+		{"柏林秋季重複 2 點", "30 2 * * *", at(berlin, 2024, 10, 26, 12, 0), []time.Time{
+			time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC), at(berlin, 2024, 10, 28, 2, 30),
+		}},
+		{"第二次經過重複時段時不再觸發", "45 1 * * *", time.Date(2024, 11, 3, 6, 10, 0, 0, time.UTC).In(ny), []time.Time{
+			at(ny, 2024, 11, 4, 1, 45),
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e, err := parseCron(c.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from := c.from
+			for i, want := range c.want {
+				got := e.Next(from)
+				if !got.Equal(want) {
+					t.Fatalf("第 %d 次觸發為 %v，期望 %v", i+1, got, want.In(c.from.Location()))
+				}
+				from = got
+			}
+		})
+	}
+}
+
+// 啟動時按錯過策略處理上次記錄之後錯過的觸發：skip 只記錄，run 補跑一次；沒有歷史時不補。
+func TestSchedulerMissedPolicy(t *testing.T) {
+	cases := []struct {
+		name    string
+		missed  string
+		history bool
+		runs    int
+		status  string // 新增的歷史記錄狀態，空表示沒有新增
+	}{
+		{"錯過就跳過", "", true, 0, "missed"},
+		{"啟動後補跑", scheduleMissedRun, true, 1, "success"},
+		{"第一次啟動不補跑", scheduleMissedRun, false, 0, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			last := time.Now().Add(-49 * time.Hour).Truncate(time.Minute)
+			if c.history {
+				appendScheduleHistory(scheduleRecord{Profile: "p", Schedule: "nightly", Planned: last, Status: "success"})
+			}
+			conf := Config{Schedules: []Schedule{{Name: "nightly", Cron: "@daily", Missed: c.missed}}}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			runs := 0
+			run := func(ctx context.Context, s Schedule) runResult {
+				runs++
+				return runResult{}
+			}
+			var next time.Time
+			runScheduler(ctx, "p", conf, run, func(name string, at time.Time) {
+				next = at
+				cancel()
+			})
+			if runs != c.runs {
+				t.Fatalf("運行了 %d 次，期望 %d 次", runs, c.runs)
+			}
+			if next.Before(time.Now()) {
+				t.Fatalf("下一次觸發 %v 已經過去", next)
+			}
+			records := loadScheduleHistory()
+			if c.history {
+				records = records[1:]
+			}
+			switch {
+			case c.status == "" && len(records) != 0:
+				t.Fatalf("不應新增記錄: %+v", records)
+			case c.status != "" && (len(records) != 1 || records[0].Status != c.status || records[0].Catchup != (c.runs > 0)):
+				t.Fatalf("新增記錄 %+v，期望一條 %s", records, c.status)
+			case c.status != "" && !records[0].Planned.After(last):
+				t.Fatalf("錯過的觸發 %v 應晚於上次記錄 %v", records[0].Planned, last)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// dragHandle 是任務行和分組標題左側的拖動把手，
+// 拖動結束時把鬆手處的絕對坐標交給 onDrop 決定落點。
+type dragHandle struct {
+	widget.Icon
+	onDrop func(pos fyne.Position)
+
+	last fyne.Position
+}
+
+func newDragHandle(onDrop func(pos fyne.Position)) *dragHandle {
+	h := &dragHandle{onDrop: onDrop}
+	h.ExtendBaseWidget(h)
+	h.Resource = theme.MenuIcon()
+	return h
+}
+
+func (h *dragHandle) Dragged(e *fyne.DragEvent) {
+	h.last = e.AbsolutePosition
+}
+
+func (h *dragHandle) DragEnd() {
+	if h.onDrop != nil {
+		h.onDrop(h.last)
+	}
+}
+
+func (h *dragHandle) Cursor() desktop.Cursor {
+	return desktop.VResizeCursor
+}
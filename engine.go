@@ -0,0 +1,519 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- 執行引擎 ---
+// 界面的整體執行、單任務/單組執行以及命令行共用同一套流程和結果彙報。
+
+type taskResult struct {
+	Name     string
+	Group    int
+	Type     TaskType
+	Start    time.Time
+	Duration time.Duration
+	ExitCode int // 命令任務的進程退出碼
+	Stats    syncStats
+	Err      error
+}
+
+// syncStats 統計一次同步的文件變化。
+type syncStats struct {
+	Copied  int
+	Skipped int
+	Deleted int
+	Bytes   int64
+	Errors  []string
+}
+
+func (s *syncStats) fail(err error) {
+	s.Errors = append(s.Errors, err.Error())
+}
+
+// Err 把逐個文件的錯誤合併成任務級別的錯誤。
+func (s *syncStats) Err() error {
+	switch len(s.Errors) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(s.Errors[0])
+	}
+	return fmt.Errorf("%d 個文件出錯，首個: %s", len(s.Errors), s.Errors[0])
+}
+
+type runResult struct {
+	Profile   string
+	Start     time.Time
+	Duration  time.Duration
+	Tasks     []taskResult
+	Cancelled bool
+}
+
+func (r runResult) Failed() []taskResult {
+	var out []taskResult
+	for _, t := range r.Tasks {
+		if t.Err != nil && !errors.Is(t.Err, context.Canceled) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// ExitCode 把運行結果換算成命令行退出碼。
+func (r runResult) ExitCode() int {
+	switch {
+	case len(r.Failed()) > 0:
+		return exitFailed
+	case r.Cancelled:
+		return exitCancelled
+	}
+	return exitOK
+}
+
+// Summary 生成一行運行結果摘要，界面狀態欄和命令行共用。
+func (r runResult) Summary() string {
+	took := r.Duration.Round(time.Millisecond)
+	if failed := r.Failed(); len(failed) > 0 {
+		return fmt.Sprintf("❌ %d/%d 個任務失敗，首個失敗: %s: %v（耗時 %s）", len(failed), len(r.Tasks), failed[0].Name, failed[0].Err, took)
+	}
+	if r.Cancelled {
+		return fmt.Sprintf("⏹ 已取消，完成 %d 個任務（耗時 %s）", len(r.Tasks), took)
+	}
+	return fmt.Sprintf("✅ 全部完成，共 %d 個任務（耗時 %s）", len(r.Tasks), took)
+}
+
+// selectTasks 按組順序列出本次要執行的任務；names 非空時只選其中列出的任務，
+// 按名稱點名的任務即使被停用也會執行。執行和預覽（plan）共用這份列表。
+func selectTasks(tasks []TaskItem, groupOrder string, names []string) []TaskItem {
+	var out []TaskItem
+	for _, gID := range strings.Split(groupOrder, ",") {
+		gID = strings.TrimSpace(gID)
+		if gID == "" {
+			continue
+		}
+		for _, t := range tasks {
+			if fmt.Sprintf("%d", t.GroupID) != gID {
+				continue
+			}
+			if len(names) > 0 && !slices.Contains(names, t.Name) || len(names) == 0 && !t.Enabled {
+				continue
+			}
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// runPipeline 依次執行 selectTasks 選出的任務，ctx 取消後不再啟動新任務。
+func runPipeline(ctx context.Context, selected []TaskItem, force bool) runResult {
+	res := runResult{Start: time.Now()}
+	defer func() { res.Duration = time.Since(res.Start) }()
+
+	progress := newProgressTracker(estimateSync(ctx, selected, force))
+	defer progress.Finish()
+
+	group := -1
+	for i, t := range selected {
+		if ctx.Err() != nil {
+			res.Cancelled = true
+			return res
+		}
+		if i == 0 || t.GroupID != group {
+			group = t.GroupID
+			publish(GroupStarted{Group: group})
+		}
+		tr := taskResult{Name: t.Name, Group: t.GroupID, Type: t.Type, Start: time.Now()}
+		publish(TaskStarted{Task: t.Name, Type: t.Type, Group: t.GroupID})
+		tr.Stats, tr.Err = runTask(ctx, t, force, progress)
+		tr.Duration = time.Since(tr.Start)
+		var exitErr *exec.ExitError
+		if errors.As(tr.Err, &exitErr) {
+			tr.ExitCode = exitErr.ExitCode()
+		}
+		res.Tasks = append(res.Tasks, tr)
+		publish(TaskFinished{Report: newTaskReport(tr)})
+		if errors.Is(tr.Err, context.Canceled) {
+			res.Cancelled = true
+			return res
+		}
+	}
+	return res
+}
+
+// lockFailed 返回因拿不到目標鎖而沒有執行的運行結果，每個任務都以該錯誤失敗。
+func lockFailed(selected []TaskItem, err error) runResult {
+	err = fmt.Errorf("無法獲取目標鎖: %w", err)
+	postStatus(err.Error())
+	res := runResult{Start: time.Now()}
+	for _, t := range selected {
+		tr := taskResult{Name: t.Name, Group: t.GroupID, Type: t.Type, Start: res.Start, Err: err}
+		res.Tasks = append(res.Tasks, tr)
+		publish(TaskFinished{Report: newTaskReport(tr)})
+	}
+	return res
+}
+
+// executeRun 執行一次運行，彙報摘要、寫出運行報告並記入運行歷史，界面和命令行共用。
+// trigger 描述觸發來源，記錄在歷史中。每次運行都以 RunStarted 開始、RunFinished 結束。
+func executeRun(ctx context.Context, trigger, profile string, c Config, order string, names []string, force bool) runResult {
+	id := newHistoryID(profile, time.Now())
+	// 歷史日誌同步接收事件，逐個文件的事件再多也不會丟行
+	var log []string
+	stopLog := events.AddSink(func(e Event) {
+		if text := e.Text(); text != "" {
+			log = append(log, time.Now().Format("15:04:05 ")+text)
+		}
+	})
+
+	selected := selectTasks(c.Tasks, order, names)
+	publish(RunStarted{ID: id, Profile: profile, Trigger: trigger, Tasks: len(selected)})
+	// 等鎖時被取消則直接結束；拿不到鎖時無法保證沒有別的進程在寫同一目標，任務全部記為失敗
+	var res runResult
+	unlock, err := lockTargets(ctx, selected)
+	switch {
+	case ctx.Err() != nil:
+		res = runResult{Profile: profile, Start: time.Now(), Cancelled: true}
+		publish(RunFinished{ID: id, Report: newRunReport(res), Result: res})
+		stopLog()
+		return res
+	case err != nil:
+		res = lockFailed(selected, err)
+	default:
+		defer unlock()
+		res = runPipeline(ctx, selected, force)
+	}
+	res.Profile = profile
+	path, err := writeReports(c, id, res)
+	if err != nil {
+		postStatus("寫入運行報告失敗: " + err.Error())
+	} else {
+		postStatus("運行報告: " + path)
+	}
+	publish(RunFinished{ID: id, Report: newRunReport(res), ReportPath: path, Result: res})
+
+	stopLog()
+	entry := historyEntry{
+		ID: id, Trigger: trigger, Profile: profile,
+		Order: order, Names: names, Force: force, Report: newRunReport(res),
+	}
+	if err := recordHistory(c, entry, log); err != nil {
+		postStatus("寫入運行歷史失敗: " + err.Error())
+	}
+	return res
+}
+
+// estimateSync 預掃描各同步任務，估算要複製的文件數和字節數。
+// 前面的命令任務可能還會改動源目錄，實際數字在任務開始時重新掃描。
+func estimateSync(ctx context.Context, tasks []TaskItem, force bool) map[string]taskEstimate {
+	estimates := map[string]taskEstimate{}
+	for _, t := range tasks {
+		if t.Type != TaskSync {
+			continue
+		}
+		t, err := expandTask(t)
+		if err != nil {
+			continue
+		}
+		src, dst, err := openSyncEnds(ctx, t.Src, t.Dst)
+		if err != nil {
+			continue
+		}
+		plan, err := scanSync(ctx, src, dst, syncOptions{Force: force, Filter: newTaskFilter(t)})
+		src.Close()
+		dst.Close()
+		if err != nil {
+			continue
+		}
+		estimates[t.Name] = taskEstimate{files: len(plan.copy), bytes: plan.bytes}
+	}
+	return estimates
+}
+
+// runTask 展開密鑰後按類型執行單個任務。
+func runTask(ctx context.Context, t TaskItem, force bool, progress *progressTracker) (syncStats, error) {
+	t, err := expandTask(t)
+	if err != nil {
+		return syncStats{}, err
+	}
+	switch t.Type {
+	case TaskSync:
+		return fullSync(ctx, t.Src, t.Dst, syncOptions{Force: force, Mirror: t.Mirror, Filter: newTaskFilter(t), Workers: t.Workers, Task: t.Name, Progress: progress})
+	case TaskCmd:
+		return syncStats{}, executeCommand(ctx, t.Name, t.Cmd, t.Root)
+	case TaskGit:
+		return publishGit(ctx, t, force, progress)
+	case TaskArchive:
+		return buildArchive(ctx, t, progress)
+	}
+	return syncStats{}, fmt.Errorf("未知任務類型: %s", t.Type)
+}
+
+// executeCommand 執行命令任務，輸出逐行作為 CommandOutput 事件發出。
+func executeCommand(ctx context.Context, task, command, dir string) error {
+	if command == "" {
+		return nil
+	}
+	args := strings.Fields(command)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	hideWindow(cmd)
+	stdout := &lineWriter{task: task}
+	stderr := &lineWriter{task: task, stderr: true}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	publish(CommandStarted{Task: task, Command: command})
+	err := cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// lineWriter 把命令輸出按行切開，每行發出一個 CommandOutput 事件。
+type lineWriter struct {
+	task   string
+	stderr bool
+	buf    []byte
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	w.buf = append(w.buf, b...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush 發出最後一段沒有換行結尾的輸出。
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) emit(line []byte) {
+	publish(CommandOutput{Task: w.task, Line: strings.TrimRight(string(line), "\r"), Stderr: w.stderr})
+}
+
+type syncOptions struct {
+	Force   bool // 不比較，全部覆蓋
+	Mirror  bool // 刪除目標中源端沒有的文件和目錄
+	Filter  taskFilter
+	Workers int // 並行傳輸的文件數，0 為默認值，見 syncWorkers
+
+	Task     string // 進度中顯示的任務名
+	Progress *progressTracker
+}
+
+// openSyncEnds 按地址打開同步任務的源和目標後端。
+func openSyncEnds(ctx context.Context, src, dst string) (storageBackend, storageBackend, error) {
+	s, err := openBackend(ctx, src)
+	if err != nil {
+		return nil, nil, fmt.Errorf("打開源 %s 失敗: %w", redactEndpoint(src), err)
+	}
+	d, err := openBackend(ctx, dst)
+	if err != nil {
+		s.Close()
+		return nil, nil, fmt.Errorf("打開目標 %s 失敗: %w", redactEndpoint(dst), err)
+	}
+	return s, d, nil
+}
+
+// syncPlan 是對比源和目標得到的複製計劃。
+type syncPlan struct {
+	copy    []fileEntry     // 需要複製的文件
+	seen    map[string]bool // 源端存在且未被過濾的條目，鏡像刪除時保留
+	target  []fileEntry     // 目標端參與同步的條目，父目錄在前
+	dirs    map[string]bool // 目標端已存在的目錄，根目錄為 ""
+	bytes   int64
+	skipped int
+	errors  []string
+}
+
+// scanSync 列出目標端和源端，找出需要複製的文件。非強制模式下未變化的文件會被跳過。
+func scanSync(ctx context.Context, src, dst storageBackend, opt syncOptions) (syncPlan, error) {
+	plan := syncPlan{seen: map[string]bool{}, dirs: map[string]bool{"": true}}
+	existing := map[string]fileEntry{}
+	err := walkBackend(ctx, dst, "", func(e fileEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				delete(plan.dirs, e.Path)
+			} else {
+				plan.errors = append(plan.errors, err.Error())
+			}
+			return nil
+		}
+		if e.IsDir {
+			if opt.Filter.Excluded(e.Path) {
+				return fs.SkipDir
+			}
+			plan.dirs[e.Path] = true
+		} else if !opt.Filter.Accepts(e.Path) {
+			return nil // 不參與同步的文件既不比較也不鏡像刪除
+		}
+		existing[e.Path] = e
+		plan.target = append(plan.target, e)
+		return nil
+	})
+	if err != nil {
+		return plan, err
+	}
+	err = walkBackend(ctx, src, "", func(e fileEntry, err error) error {
+		if err != nil {
+			plan.errors = append(plan.errors, err.Error())
+			return nil
+		}
+		if e.IsDir {
+			if opt.Filter.Excluded(e.Path) {
+				return fs.SkipDir
+			}
+			plan.seen[e.Path] = true
+			return nil
+		}
+		if !opt.Filter.Accepts(e.Path) {
+			return nil
+		}
+		plan.seen[e.Path] = true
+		if t, ok := existing[e.Path]; ok && !opt.Force && unchanged(ctx, src, dst, e, t) {
+			plan.skipped++
+			return nil
+		}
+		plan.copy = append(plan.copy, e)
+		plan.bytes += e.Size
+		return nil
+	})
+	return plan, err
+}
+
+// fullSync 按 scanSync 的計劃把 src 複製到 dst，並彙報進度。
+func fullSync(ctx context.Context, src, dst string, opt syncOptions) (syncStats, error) {
+	s, d, err := openSyncEnds(ctx, src, dst)
+	if err != nil {
+		return syncStats{}, err
+	}
+	defer s.Close()
+	defer d.Close()
+	plan, err := scanSync(ctx, s, d, opt)
+	stats := syncStats{Skipped: plan.skipped, Errors: plan.errors}
+	if err != nil {
+		return stats, err
+	}
+	opt.Progress.StartTask(opt.Task, len(plan.copy), plan.bytes)
+	// 目錄按計劃順序（父目錄在前）先建好，之後的複製互不依賴，可以並行
+	var jobs []fileEntry
+	for _, e := range plan.copy {
+		if ctx.Err() != nil {
+			return stats, ctx.Err()
+		}
+		if err := ensureDir(ctx, d, parentDir(e.Path), plan.dirs); err != nil {
+			stats.fail(err)
+			continue
+		}
+		jobs = append(jobs, e)
+	}
+	copyParallel(ctx, s, d, jobs, syncWorkers(s, d, opt.Workers), opt, &stats)
+	if ctx.Err() != nil {
+		return stats, ctx.Err()
+	}
+	// 鏡像刪除放在所有複製完成之後
+	if opt.Mirror && len(stats.Errors) == 0 {
+		if err := mirrorDelete(ctx, d, plan, opt, &stats); err != nil {
+			return stats, err
+		}
+	}
+	return stats, stats.Err()
+}
+
+// copyParallel 用 workers 個協程複製 jobs，結果按 jobs 的順序計入 stats，錯誤列表與順序複製時一致。
+func copyParallel(ctx context.Context, src, dst storageBackend, jobs []fileEntry, workers int, opt syncOptions, stats *syncStats) {
+	errs := make([]error, len(jobs))
+	done := make([]bool, len(jobs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				e := jobs[i]
+				if errs[i] = copyFile(ctx, src, dst, e, opt.Progress); errs[i] != nil {
+					continue
+				}
+				done[i] = true
+				opt.Progress.Add(1, 0)
+				publish(FileCopied{Task: opt.Task, Path: e.Path, Bytes: e.Size})
+			}
+		}()
+	}
+send:
+	for i := range jobs {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(next)
+	wg.Wait()
+	for i, e := range jobs {
+		switch {
+		case done[i]:
+			stats.Copied++
+			stats.Bytes += e.Size
+		case errs[i] != nil && ctx.Err() == nil:
+			stats.fail(errs[i])
+		}
+	}
+}
+
+// ensureDir 在目標端創建目錄，known 中記錄已存在的目錄，避免對遠程後端重複請求。
+func ensureDir(ctx context.Context, b storageBackend, dir string, known map[string]bool) error {
+	if known[dir] {
+		return nil
+	}
+	if err := b.Mkdir(ctx, dir); err != nil {
+		return err
+	}
+	for {
+		known[dir] = true
+		if dir == "" {
+			return nil
+		}
+		dir = parentDir(dir)
+	}
+}
+
+// copyFile 把源文件寫到目標端並保留修改時間，供下次同步比較。
+func copyFile(ctx context.Context, src, dst storageBackend, e fileEntry, progress *progressTracker) error {
+	r, err := src.Open(ctx, e.Path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	pr := &progressReader{r: r, t: progress}
+	if err := dst.Write(ctx, e.Path, pr, e); err != nil {
+		pr.Rollback()
+		return err
+	}
+	return nil
+}
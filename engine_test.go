@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// listFiles 列出目錄下所有文件的相對路徑。
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var out []string
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		out = append(out, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// 鏡像刪除只刪源端確實沒有的文件：不在 include 中或被 exclude 排除的目標文件保留。
+func TestMirrorKeepsFilteredFiles(t *testing.T) {
+	tmp := t.TempDir()
+	src, dst := filepath.Join(tmp, "src"), filepath.Join(tmp, "dst")
+	writeFiles(t, src, map[string]string{"index.html": "new", "style.css": "css", "blog/post.html": "post", "drafts/a.html": "draft"})
+	writeFiles(t, dst, map[string]string{
+		"index.html": "old", "style.css": "css", "blog/post.html": "post",
+		"old.html": "gone", "blog/old.css": "keep", "drafts/keep.html": "keep", "empty/gone.html": "gone",
+	})
+	task := TaskItem{Include: "*.html", Exclude: "drafts"}
+	opt := syncOptions{Force: true, Mirror: true, Filter: newTaskFilter(task)}
+	stats, err := fullSync(context.Background(), src, dst, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"blog/old.css", "blog/post.html", "drafts/keep.html", "index.html", "style.css"}
+	if got := listFiles(t, dst); !slices.Equal(got, want) {
+		t.Fatalf("目標剩下 %v，期望 %v", got, want)
+	}
+	if stats.Copied != 2 || stats.Deleted != 2 {
+		t.Fatalf("複製 %d 個、刪除 %d 個，期望 2 和 2", stats.Copied, stats.Deleted)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "empty")); !os.IsNotExist(err) {
+		t.Fatalf("清空的目錄應被刪除: %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dst, "index.html")); string(b) != "new" {
+		t.Fatalf("index.html = %q", b)
+	}
+}
+
+// recordingBackend 包裝本地目錄，記錄寫操作的順序和同時進行的寫入數，並讓指定文件寫入失敗。
+type recordingBackend struct {
+	storageBackend
+	limit int
+	fail  map[string]bool
+
+	mu        sync.Mutex
+	ops       []string // "mkdir 路徑"、"write 路徑"、"remove 路徑"
+	active    int
+	maxActive int
+}
+
+func (b *recordingBackend) record(op, p string) {
+	b.mu.Lock()
+	b.ops = append(b.ops, op+" "+p)
+	b.mu.Unlock()
+}
+
+func (b *recordingBackend) MaxTransfers() int { return b.limit }
+
+func (b *recordingBackend) Mkdir(ctx context.Context, p string) error {
+	b.record("mkdir", p)
+	return b.storageBackend.Mkdir(ctx, p)
+}
+
+func (b *recordingBackend) Remove(ctx context.Context, p string) error {
+	b.record("remove", p)
+	return b.storageBackend.Remove(ctx, p)
+}
+
+func (b *recordingBackend) Write(ctx context.Context, p string, r io.Reader, e fileEntry) error {
+	b.mu.Lock()
+	b.ops = append(b.ops, "write "+p)
+	b.active++
+	b.maxActive = max(b.maxActive, b.active)
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.active--
+		b.mu.Unlock()
+	}()
+	time.Sleep(20 * time.Millisecond) // 讓並行的寫入有機會重疊
+	if b.fail[p] {
+		io.Copy(io.Discard, r)
+		return fmt.Errorf("寫入 %s 失敗", p)
+	}
+	return b.storageBackend.Write(ctx, p, r, e)
+}
+
+// useRecordingBackend 把 dir 包裝成 recordingBackend，通過 rec:// 地址交給 fullSync。
+func useRecordingBackend(t *testing.T, dir string, limit int, fail ...string) *recordingBackend {
+	t.Helper()
+	local, err := openBackend(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &recordingBackend{storageBackend: local, limit: limit, fail: map[string]bool{}}
+	for _, p := range fail {
+		b.fail[p] = true
+	}
+	backendSchemes["rec"] = func(ctx context.Context, u *url.URL) (storageBackend, error) { return b, nil }
+	t.Cleanup(func() { delete(backendSchemes, "rec") })
+	return b
+}
+
+// 目錄在文件之前建好，鏡像刪除在所有複製完成之後，並行寫入不超過目標後端的上限。
+func TestFullSyncOrder(t *testing.T) {
+	tmp := t.TempDir()
+	src, dst := filepath.Join(tmp, "src"), filepath.Join(tmp, "dst")
+	files := map[string]string{}
+	for i := range 6 {
+		files[fmt.Sprintf("posts/%d/index.html", i)] = "post"
+		files[fmt.Sprintf("page%d.html", i)] = "page"
+	}
+	writeFiles(t, src, files)
+	writeFiles(t, dst, map[string]string{"old.html": "gone", "old/a.html": "gone"})
+	b := useRecordingBackend(t, dst, 2)
+
+	stats, err := fullSync(context.Background(), src, "rec://dst", syncOptions{Mirror: true, Workers: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Copied != 12 || stats.Deleted != 2 {
+		t.Fatalf("複製 %d 個、刪除 %d 個，期望 12 和 2", stats.Copied, stats.Deleted)
+	}
+	lastMkdir, firstWrite, lastWrite, firstRemove := -1, len(b.ops), -1, len(b.ops)
+	for i, op := range b.ops {
+		kind, _, _ := strings.Cut(op, " ")
+		switch kind {
+		case "mkdir":
+			lastMkdir = i
+		case "write":
+			firstWrite, lastWrite = min(firstWrite, i), i
+		case "remove":
+			firstRemove = min(firstRemove, i)
+		}
+	}
+	if lastMkdir > firstWrite || lastWrite > firstRemove {
+		t.Fatalf("操作順序不對: %v", b.ops)
+	}
+	if b.maxActive != 2 {
+		t.Fatalf("同時寫入 %d 個，期望不超過目標後端的上限 2 且確實並行", b.maxActive)
+	}
+	if got := listFiles(t, dst); len(got) != 12 || slices.Contains(got, "old.html") {
+		t.Fatalf("目標文件 = %v", got)
+	}
+}
+
+// 並行複製的錯誤按文件順序彙總，其餘文件照常複製；有錯誤時不做鏡像刪除。
+func TestFullSyncCollectsErrors(t *testing.T) {
+	tmp := t.TempDir()
+	src, dst := filepath.Join(tmp, "src"), filepath.Join(tmp, "dst")
+	writeFiles(t, src, map[string]string{"a.html": "a", "b.html": "b", "c.html": "c", "d.html": "d", "e.html": "e"})
+	writeFiles(t, dst, map[string]string{"old.html": "keep"})
+	useRecordingBackend(t, dst, 0, "b.html", "d.html")
+
+	stats, err := fullSync(context.Background(), src, "rec://dst", syncOptions{Mirror: true, Workers: 4})
+	if err == nil {
+		t.Fatal("有文件寫入失敗時應返回錯誤")
+	}
+	want := []string{"寫入 b.html 失敗", "寫入 d.html 失敗"}
+	if !slices.Equal(stats.Errors, want) || stats.Copied != 3 || stats.Deleted != 0 {
+		t.Fatalf("錯誤 %v、複製 %d 個、刪除 %d 個，期望 %v、3 和 0", stats.Errors, stats.Copied, stats.Deleted, want)
+	}
+	if got := listFiles(t, dst); !slices.Equal(got, []string{"a.html", "c.html", "e.html", "old.html"}) {
+		t.Fatalf("目標文件 = %v", got)
+	}
+}
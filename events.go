@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// --- 事件總線 ---
+// 引擎、隊列、計劃和監視發出帶類型的事件，界面、終端、運行歷史和 HTTP 接口各自訂閱。
+// 發佈從不阻塞：訂閱者的緩衝區滿了就丟棄事件並記數，騰出空間後先補發一個 Dropped。
+// 不能丟事件的（運行歷史的日誌）註冊為接收函數，在發佈時同步調用。
+
+type Event interface {
+	Kind() string
+	Text() string // 一行人類可讀的描述，進度等不需要顯示的事件返回空串
+}
+
+// Message 是沒有專門類型的普通狀態消息。
+type Message struct {
+	Msg string `json:"msg"`
+}
+
+type RunStarted struct {
+	ID      string `json:"id"`
+	Profile string `json:"profile"`
+	Trigger string `json:"trigger"`
+	Tasks   int    `json:"tasks"`
+}
+
+type GroupStarted struct {
+	Group int `json:"group"`
+}
+
+type TaskStarted struct {
+	Task  string   `json:"task"`
+	Type  TaskType `json:"type"`
+	Group int      `json:"group"`
+}
+
+type FileCopied struct {
+	Task  string `json:"task"`
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+type FileDeleted struct {
+	Task string `json:"task"`
+	Path string `json:"path"`
+}
+
+type CommandStarted struct {
+	Task    string `json:"task"`
+	Command string `json:"command"`
+}
+
+type CommandOutput struct {
+	Task   string `json:"task"`
+	Line   string `json:"line"`
+	Stderr bool   `json:"stderr,omitempty"`
+}
+
+type TaskFinished struct {
+	Report taskReport `json:"report"`
+}
+
+type Progress struct {
+	runProgress
+}
+
+type RunFinished struct {
+	ID         string    `json:"id"`
+	Report     runReport `json:"report"`
+	ReportPath string    `json:"report_path,omitempty"`
+	Result     runResult `json:"-"`
+}
+
+// Dropped 表示訂閱者處理不過來，中間丟掉了 Count 個事件。
+type Dropped struct {
+	Count int `json:"count"`
+}
+
+func (Message) Kind() string        { return "message" }
+func (RunStarted) Kind() string     { return "run_started" }
+func (GroupStarted) Kind() string   { return "group_started" }
+func (TaskStarted) Kind() string    { return "task_started" }
+func (FileCopied) Kind() string     { return "file_copied" }
+func (FileDeleted) Kind() string    { return "file_deleted" }
+func (CommandStarted) Kind() string { return "command_started" }
+func (CommandOutput) Kind() string  { return "command_output" }
+func (TaskFinished) Kind() string   { return "task_finished" }
+func (Progress) Kind() string       { return "progress" }
+func (RunFinished) Kind() string    { return "run_finished" }
+func (Dropped) Kind() string        { return "dropped" }
+
+func (e Message) Text() string { return e.Msg }
+func (e RunStarted) Text() string {
+	return fmt.Sprintf("開始運行 %s（%s，%d 個任務）", e.Profile, e.Trigger, e.Tasks)
+}
+func (e GroupStarted) Text() string {
+	return fmt.Sprintf("正在運行組: %d", e.Group)
+}
+func (e TaskStarted) Text() string    { return "任務: " + e.Task }
+func (e FileCopied) Text() string     { return "同步: " + e.Path }
+func (e FileDeleted) Text() string    { return "刪除: " + e.Path }
+func (e CommandStarted) Text() string { return "運行中: " + e.Command }
+func (e CommandOutput) Text() string  { return "  " + e.Line }
+func (e TaskFinished) Text() string {
+	switch e.Report.Status {
+	case "failed":
+		return "❌ " + e.Report.Name + ": " + e.Report.Error
+	case "cancelled":
+		return "已取消: " + e.Report.Name
+	}
+	return "完成: " + e.Report.Name
+}
+func (Progress) Text() string      { return "" }
+func (e RunFinished) Text() string { return e.Result.Summary() }
+func (e Dropped) Text() string {
+	return fmt.Sprintf("（處理不及，丟棄了 %d 個事件）", e.Count)
+}
+
+type subscriber struct {
+	ch      chan Event
+	dropped int
+}
+
+type eventBus struct {
+	mu    sync.Mutex
+	subs  map[int]*subscriber
+	sinks map[int]func(Event)
+	seq   int
+}
+
+var events = &eventBus{subs: map[int]*subscriber{}, sinks: map[int]func(Event){}}
+
+// Subscribe 訂閱之後發佈的所有事件，返回事件通道和取消訂閱的函數。
+// 取消訂閱後通道會被關閉，緩衝中尚未讀取的事件仍可讀完。
+func (b *eventBus) Subscribe(buffer int) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	id := b.seq
+	s := &subscriber{ch: make(chan Event, buffer)}
+	b.subs[id] = s
+	return s.ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[id]; ok {
+			delete(b.subs, id)
+			close(s.ch)
+		}
+	}
+}
+
+// AddSink 註冊一個接收函數，之後發佈的每個事件都會在 Publish 返回前交給它，不會丟失。
+// 調用在總線的鎖內依次進行，fn 必須很快返回且不能再發佈事件。返回移除函數，
+// 移除後 fn 不會再被調用。
+func (b *eventBus) AddSink(fn func(Event)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	id := b.seq
+	b.sinks[id] = fn
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.sinks, id)
+	}
+}
+
+// Publish 把事件交給所有接收函數和訂閱者，不等待任何訂閱者。
+func (b *eventBus) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, fn := range b.sinks {
+		fn(e)
+	}
+	for _, s := range b.subs {
+		if s.dropped > 0 {
+			select {
+			case s.ch <- Dropped{Count: s.dropped}:
+				s.dropped = 0
+			default:
+				s.dropped++
+				continue
+			}
+		}
+		select {
+		case s.ch <- e:
+		default:
+			s.dropped++
+		}
+	}
+}
+
+func publish(e Event) { events.Publish(e) }
+
+// postStatus 發出一條普通狀態消息。
+func postStatus(s string) { publish(Message{Msg: s}) }
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+import "os/exec"
+
+// hideWindow 在非 Windows 系統上無需處理。
+func hideWindow(cmd *exec.Cmd) {}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// --- 路徑過濾 ---
+// 任務的 include / exclude 為逗號分隔的通配符，同時匹配文件名和以 / 分隔的相對路徑。
+// 被排除的目錄整棵跳過；include 只作用於文件，留空表示全部包含。
+// 鏡像刪除不會動目標端被排除的路徑。監視模式、ARCHIVE 和 GIT 任務共用同一套規則。
+
+type taskFilter struct {
+	include []string
+	exclude []string
+}
+
+func newTaskFilter(t TaskItem) taskFilter {
+	return taskFilter{include: splitPatterns(t.Include), exclude: splitPatterns(t.Exclude)}
+}
+
+func splitPatterns(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, filepath.ToSlash(p))
+		}
+	}
+	return out
+}
+
+func matchAny(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, path.Base(rel)); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (f taskFilter) Excluded(rel string) bool {
+	return matchAny(f.exclude, rel)
+}
+
+// Accepts 判斷文件是否參與同步。
+func (f taskFilter) Accepts(rel string) bool {
+	if f.Excluded(rel) {
+		return false
+	}
+	return len(f.include) == 0 || matchAny(f.include, rel)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- FTP 協議 ---
+// 只實現同步需要的命令：被動模式數據連接、MLSD/MLST 列表、顯式 TLS（AUTH TLS）。
+// 上下文取消時通過截止時間打斷阻塞的讀寫，連接隨後作廢。
+
+type ftpConn struct {
+	conn  net.Conn
+	text  *textproto.Conn
+	tls   *tls.Config // 非 nil 時數據連接同樣加密
+	feats map[string]string
+}
+
+// ftpError 是服務器的錯誤應答，連接本身仍可繼續使用。
+type ftpError struct {
+	Cmd  string
+	Code int
+	Msg  string
+}
+
+func (e *ftpError) Error() string {
+	return fmt.Sprintf("ftp %s: %d %s", e.Cmd, e.Code, e.Msg)
+}
+
+// Is 把 550（文件不可用）視為不存在，FTP 沒有更細的區分。
+func (e *ftpError) Is(target error) bool {
+	return target == fs.ErrNotExist && e.Code == 550
+}
+
+// dialFTP 連接並登錄；tlsConf 非 nil 時先升級為 TLS 並要求數據連接加密。
+func dialFTP(ctx context.Context, addr, user, pass string, tlsConf *tls.Config) (*ftpConn, error) {
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &ftpConn{conn: nc, text: textproto.NewConn(nc), feats: map[string]string{}}
+	stop := c.watch(ctx)
+	defer stop()
+	if err := c.login(user, pass, tlsConf); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *ftpConn) login(user, pass string, tlsConf *tls.Config) error {
+	if _, _, err := c.text.ReadResponse(2); err != nil {
+		return fmt.Errorf("ftp 歡迎信息: %w", err)
+	}
+	if tlsConf != nil {
+		if _, _, err := c.cmd(2, "AUTH TLS"); err != nil {
+			return err
+		}
+		tc := tls.Client(c.conn, tlsConf)
+		if err := tc.Handshake(); err != nil {
+			return err
+		}
+		c.conn, c.text, c.tls = tc, textproto.NewConn(tc), tlsConf
+	}
+	code, _, err := c.cmd(0, "USER %s", user)
+	switch {
+	case err != nil:
+		return err
+	case code == 331:
+		if _, _, err := c.cmd(2, "PASS %s", pass); err != nil {
+			return err
+		}
+	case code/100 != 2:
+		return &ftpError{Cmd: "USER", Code: code}
+	}
+	if c.tls != nil {
+		if _, _, err := c.cmd(2, "PBSZ 0"); err != nil {
+			return err
+		}
+		if _, _, err := c.cmd(2, "PROT P"); err != nil {
+			return err
+		}
+	}
+	if _, msg, err := c.cmd(2, "FEAT"); err == nil {
+		for _, line := range strings.Split(msg, "\n") {
+			name, params, _ := strings.Cut(strings.TrimSpace(line), " ")
+			c.feats[strings.ToUpper(name)] = params
+		}
+	}
+	if _, ok := c.feats["MLST"]; !ok {
+		return errors.New("ftp 服務器不支持 MLSD/MLST，無法比較文件")
+	}
+	if _, ok := c.feats["UTF8"]; ok {
+		c.cmd(2, "OPTS UTF8 ON")
+	}
+	_, _, err = c.cmd(2, "TYPE I")
+	return err
+}
+
+// watch 在 ctx 取消時打斷連接上的阻塞操作，返回停止監視的函數。
+func (c *ftpConn) watch(ctx context.Context) func() bool {
+	return context.AfterFunc(ctx, func() { c.conn.SetDeadline(time.Now()) })
+}
+
+// cmd 發送命令並讀取應答。expect 為 0 時不檢查應答碼，否則按 textproto 的前綴規則檢查。
+func (c *ftpConn) cmd(expect int, format string, args ...any) (int, string, error) {
+	verb, _, _ := strings.Cut(format, " ")
+	if _, err := c.text.Cmd(format, args...); err != nil {
+		return 0, "", err
+	}
+	code, msg, err := c.text.ReadResponse(expect)
+	var te *textproto.Error
+	if errors.As(err, &te) {
+		return code, msg, &ftpError{Cmd: verb, Code: te.Code, Msg: te.Msg}
+	}
+	return code, msg, err
+}
+
+// passive 打開被動模式的數據連接，優先 EPSV。PASV 返回的地址忽略，改用控制連接的地址，避免 NAT 後的內網地址。
+func (c *ftpConn) passive(ctx context.Context) (net.Conn, error) {
+	host, _, _ := net.SplitHostPort(c.conn.RemoteAddr().String())
+	var port int
+	if _, msg, err := c.cmd(229, "EPSV"); err == nil {
+		start, end := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if start < 0 || end < start+4 {
+			return nil, fmt.Errorf("ftp EPSV 應答格式錯誤: %s", msg)
+		}
+		port, err = strconv.Atoi(msg[start+4 : end])
+		if err != nil {
+			return nil, fmt.Errorf("ftp EPSV 應答格式錯誤: %s", msg)
+		}
+	} else {
+		_, msg, err := c.cmd(227, "PASV")
+		if err != nil {
+			return nil, err
+		}
+		start, end := strings.Index(msg, "("), strings.Index(msg, ")")
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("ftp PASV 應答格式錯誤: %s", msg)
+		}
+		f := strings.Split(msg[start+1:end], ",")
+		if len(f) != 6 {
+			return nil, fmt.Errorf("ftp PASV 應答格式錯誤: %s", msg)
+		}
+		hi, _ := strconv.Atoi(f[4])
+		lo, _ := strconv.Atoi(f[5])
+		port = hi<<8 | lo
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// transfer 打開數據連接並發出傳輸命令（RETR、STOR、MLSD），
+// 數據傳完後需關閉數據連接並調用 finish 讀取最終應答。
+func (c *ftpConn) transfer(ctx context.Context, format string, args ...any) (net.Conn, error) {
+	dc, err := c.passive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := c.cmd(1, format, args...); err != nil {
+		dc.Close()
+		return nil, err
+	}
+	if c.tls != nil {
+		// 很多服務器要求數據連接複用控制連接的 TLS 會話
+		dc = tls.Client(dc, c.tls)
+	}
+	return dc, nil
+}
+
+func (c *ftpConn) finish() error {
+	_, _, err := c.text.ReadResponse(2)
+	var te *textproto.Error
+	if errors.As(err, &te) {
+		return &ftpError{Cmd: "傳輸", Code: te.Code, Msg: te.Msg}
+	}
+	return err
+}
+
+func (c *ftpConn) Close() error {
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	c.cmd(2, "QUIT")
+	return c.conn.Close()
+}
+
+// parseMLSx 解析 MLSD/MLST 的一行「fact=值;fact=值; 名稱」。
+func parseMLSx(line string) (string, fileEntry, bool) {
+	facts, name, ok := strings.Cut(line, " ")
+	if !ok || name == "" {
+		return "", fileEntry{}, false
+	}
+	var e fileEntry
+	for _, f := range strings.Split(facts, ";") {
+		k, v, _ := strings.Cut(f, "=")
+		switch strings.ToLower(k) {
+		case "type":
+			switch strings.ToLower(v) {
+			case "dir":
+				e.IsDir = true
+			case "cdir", "pdir":
+				return "", fileEntry{}, false
+			}
+		case "size":
+			e.Size, _ = strconv.ParseInt(v, 10, 64)
+		case "modify":
+			if len(v) >= 14 {
+				e.ModTime, _ = time.Parse("20060102150405", v[:14])
+			}
+		}
+	}
+	return name, e, true
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Git 發布 ---
+// GIT 任務把源目錄鏡像到目標倉庫某個分支的本地工作區，有變化時提交並推送到 Dst 指定的遠程，
+// 適用於從分支部署的靜態托管（gh-pages 之類）。工作區保留在 Root（默認 git-publish/<任務名>），
+// 下次只需增量拉取。遠程分支不存在時新建無歷史的分支。
+// 工作區會被強制檢出和清理，Root 只接受空目錄或本工具建立的工作區（.git 中有標記文件）。
+// http(s) 遠程地址中的用戶名和密碼不寫入 .git/config，運行 git 時經環境變量交給臨時的憑據助手。
+
+const (
+	gitPublishDir     = "git-publish"
+	gitMarker         = "hugo-sync-worktree" // 位於 .git 目錄中
+	defaultGitBranch  = "gh-pages"
+	defaultGitMessage = "{task}: 發布 {changed} 個文件（新增 {added}，修改 {modified}，刪除 {deleted}）"
+)
+
+// gitCredentialHelper 從環境變量回答 git 的憑據查詢，本身不含密碼。
+const gitCredentialHelper = `!f() { test "$1" = get && printf 'username=%s\npassword=%s\n' "$HUGO_SYNC_GIT_USER" "$HUGO_SYNC_GIT_PASS"; }; f`
+
+// gitChanges 統計暫存區相對於 HEAD 的變化。
+type gitChanges struct {
+	Added, Modified, Deleted int
+}
+
+func (c gitChanges) Total() int { return c.Added + c.Modified + c.Deleted }
+
+// gitWorktree 返回任務的本地工作區目錄。
+func gitWorktree(t TaskItem) string {
+	if t.Root != "" {
+		return t.Root
+	}
+	return filepath.Join(gitPublishDir, t.Name)
+}
+
+// publishGit 執行 GIT 任務：準備工作區、鏡像同步、提交並推送。
+func publishGit(ctx context.Context, t TaskItem, force bool, progress *progressTracker) (syncStats, error) {
+	dir := gitWorktree(t)
+	branch := t.Branch
+	if branch == "" {
+		branch = defaultGitBranch
+	}
+	remote, user, pass := gitCredentials(t.Dst)
+	g := gitRunner{task: t.Name, dir: dir, owned: t.Root == "", user: user, pass: pass}
+	if err := g.prepare(ctx, remote, branch); err != nil {
+		return syncStats{}, err
+	}
+
+	filter := newTaskFilter(t)
+	filter.exclude = append(filter.exclude, ".git")
+	stats, err := fullSync(ctx, t.Src, dir, syncOptions{Force: force, Mirror: true, Filter: filter, Workers: t.Workers, Task: t.Name, Progress: progress})
+	if err != nil {
+		return stats, err
+	}
+
+	if _, err := g.run(ctx, "add", "-A"); err != nil {
+		return stats, err
+	}
+	changes, err := g.staged(ctx)
+	if err != nil {
+		return stats, err
+	}
+	if changes.Total() > 0 {
+		msg := expandTemplate(cmp.Or(t.Message, defaultGitMessage), map[string]string{
+			"task":     t.Name,
+			"branch":   branch,
+			"added":    strconv.Itoa(changes.Added),
+			"modified": strconv.Itoa(changes.Modified),
+			"deleted":  strconv.Itoa(changes.Deleted),
+			"changed":  strconv.Itoa(changes.Total()),
+			"date":     time.Now().Format("2006-01-02"),
+			"time":     time.Now().Format("2006-01-02 15:04:05"),
+		})
+		args := []string{"commit", "-q", "-m", msg}
+		if _, err := g.run(ctx, "config", "user.email"); err != nil {
+			// 沒有配置提交身份時使用工具自身的名字
+			args = append([]string{"-c", "user.name=hugo-sync", "-c", "user.email=hugo-sync@localhost"}, args...)
+		}
+		if _, err := g.run(ctx, args...); err != nil {
+			return stats, err
+		}
+		publish(CommandOutput{Task: t.Name, Line: "已提交: " + msg})
+	} else {
+		publish(CommandOutput{Task: t.Name, Line: "工作區沒有變化，不需要提交"})
+	}
+	// 上次推送失敗留下的提交也一併推送；沒有任何提交的新分支則無需推送
+	if _, err := g.run(ctx, "rev-parse", "-q", "--verify", "HEAD"); err != nil {
+		return stats, nil
+	}
+	_, err = g.run(ctx, "push", "origin", "HEAD:refs/heads/"+branch)
+	return stats, err
+}
+
+// expandTemplate 把 {名稱} 替換成 vars 中的值，未知的佔位符保持原樣。
+func expandTemplate(s string, vars map[string]string) string {
+	pairs := make([]string, 0, len(vars)*2)
+	for k, v := range vars {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// gitCredentials 拆出 http(s) 遠程地址中的用戶信息，返回不帶用戶信息的地址。
+// 其他形式的遠程（SSH、本地路徑）原樣返回。
+func gitCredentials(remote string) (clean, user, pass string) {
+	u, err := url.Parse(remote)
+	if err != nil || u.User == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return remote, "", ""
+	}
+	user = u.User.Username()
+	pass, _ = u.User.Password()
+	u.User = nil
+	return u.String(), user, pass
+}
+
+// gitRunner 在工作區中執行 git 命令，錯誤輸出逐行作為 CommandOutput 事件發出。
+type gitRunner struct {
+	task  string
+	dir   string
+	owned bool // 工作區在默認位置，只有本工具會寫
+	user  string
+	pass  string
+}
+
+func (g gitRunner) run(ctx context.Context, args ...string) (string, error) {
+	full := args
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if g.user != "" || g.pass != "" {
+		// 先清空已配置的助手，避免系統的憑據管理器搶先應答或把密碼存下來
+		full = append([]string{"-c", "credential.helper=", "-c", "credential.helper=" + gitCredentialHelper}, args...)
+		env = append(env, "HUGO_SYNC_GIT_USER="+g.user, "HUGO_SYNC_GIT_PASS="+g.pass)
+	}
+	cmd := exec.CommandContext(ctx, "git", full...)
+	cmd.Dir = g.dir
+	cmd.Env = env
+	hideWindow(cmd)
+	var stdout, errText bytes.Buffer
+	stderr := &lineWriter{task: g.task, stderr: true}
+	cmd.Stdout = &stdout
+	cmd.Stderr = io.MultiWriter(stderr, &errText)
+	err := cmd.Run()
+	stderr.Flush()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		msg := strings.TrimSpace(errText.String())
+		if i := strings.LastIndexByte(msg, '\n'); i >= 0 {
+			msg = msg[i+1:]
+		}
+		return "", fmt.Errorf("git %s 失敗: %w %s", args[0], err, msg)
+	}
+	return stdout.String(), nil
+}
+
+// prepare 讓工作區指向 remote 的 branch 分支並與之一致；遠程沒有該分支時切到一個空的新分支。
+func (g gitRunner) prepare(ctx context.Context, remote, branch string) error {
+	fresh, err := g.claim()
+	if err != nil {
+		return err
+	}
+	if fresh {
+		if _, err := g.run(ctx, "init", "-q"); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(g.dir, ".git", gitMarker), nil, 0644); err != nil {
+			return err
+		}
+		if _, err := g.run(ctx, "remote", "add", "origin", remote); err != nil {
+			return err
+		}
+	} else if _, err := g.run(ctx, "remote", "set-url", "origin", remote); err != nil {
+		return err
+	}
+	heads, err := g.run(ctx, "ls-remote", "--heads", "origin", "refs/heads/"+branch)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(heads) == "" {
+		publish(CommandOutput{Task: g.task, Line: "遠程沒有分支 " + branch + "，將新建"})
+		if _, err := g.run(ctx, "symbolic-ref", "HEAD", "refs/heads/"+branch); err != nil {
+			return err
+		}
+		_, err = g.run(ctx, "read-tree", "--empty")
+		return err
+	}
+	if _, err := g.run(ctx, "fetch", "-q", "origin", "refs/heads/"+branch); err != nil {
+		return err
+	}
+	if _, err := g.run(ctx, "checkout", "-q", "-f", "-B", branch, "FETCH_HEAD"); err != nil {
+		return err
+	}
+	// 清掉上次中斷時留下的未跟蹤文件
+	_, err = g.run(ctx, "clean", "-q", "-fdx")
+	return err
+}
+
+// claim 確認工作區可以交給本工具強制檢出和清理，返回是否需要新建倉庫。
+// 目錄不存在或為空時可用；非空時必須帶有標記，默認位置下早期版本建立的工作區補上標記。
+func (g gitRunner) claim() (fresh bool, err error) {
+	marker := filepath.Join(g.dir, ".git", gitMarker)
+	if _, err := os.Stat(marker); err == nil {
+		return false, nil
+	}
+	entries, err := os.ReadDir(g.dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return true, os.MkdirAll(g.dir, 0755)
+	case err != nil:
+		return false, err
+	case len(entries) == 0:
+		return true, nil
+	}
+	if st, err := os.Stat(filepath.Join(g.dir, ".git")); err == nil && st.IsDir() && g.owned {
+		return false, os.WriteFile(marker, nil, 0644)
+	}
+	return false, fmt.Errorf("工作區 %s 不是空目錄，也不是本工具建立的 git 工作區；發布會強制檢出並清理其中的文件，請把 root 指向一個空目錄", g.dir)
+}
+
+// staged 按 git diff --cached --name-status 統計變化，改名算作刪除加新增。
+// 新分支還沒有 HEAD 時 git 與空樹比較。
+func (g gitRunner) staged(ctx context.Context) (gitChanges, error) {
+	out, err := g.run(ctx, "diff", "--cached", "--name-status", "--no-renames")
+	if err != nil {
+		return gitChanges{}, err
+	}
+	var c gitChanges
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "A"):
+			c.Added++
+		case strings.HasPrefix(line, "D"):
+			c.Deleted++
+		case line != "":
+			c.Modified++
+		}
+	}
+	return c, nil
+}
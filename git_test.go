@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gitTest 準備不受本機 git 配置影響的環境，返回臨時目錄。沒有 git 時跳過。
+func gitTest(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("需要 git")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	return t.TempDir()
+}
+
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// branchFiles 列出裸倉庫中分支的文件和提交數。
+func branchFiles(t *testing.T, bare, branch string) ([]string, string) {
+	t.Helper()
+	files := strings.Split(gitOutput(t, bare, "ls-tree", "-r", "--name-only", branch), "\n")
+	return files, gitOutput(t, bare, "rev-list", "--count", branch)
+}
+
+func TestPublishGitLocalBare(t *testing.T) {
+	tmp := gitTest(t)
+	bare := filepath.Join(tmp, "site.git")
+	gitOutput(t, tmp, "init", "-q", "--bare", bare)
+	src := filepath.Join(tmp, "public")
+	writeFiles(t, src, map[string]string{"index.html": "v1", "css/a.css": "a", "draft.tmp": "x"})
+	task := TaskItem{Name: "pages", Type: TaskGit, Src: src, Dst: bare, Root: filepath.Join(tmp, "work"), Exclude: "*.tmp"}
+
+	if _, err := publishGit(context.Background(), task, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	files, count := branchFiles(t, bare, defaultGitBranch)
+	if !slices.Equal(files, []string{"css/a.css", "index.html"}) || count != "1" {
+		t.Fatalf("首次發布後分支有 %v，%s 個提交", files, count)
+	}
+
+	// 沒有變化時不提交
+	if _, err := publishGit(context.Background(), task, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, count := branchFiles(t, bare, defaultGitBranch); count != "1" {
+		t.Fatalf("沒有變化卻多了提交，共 %s 個", count)
+	}
+
+	// 大小不變，改修改時間讓增量比較看到變化
+	writeFiles(t, src, map[string]string{"index.html": "v2"})
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(src, "index.html"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(src, "css", "a.css"))
+	if _, err := publishGit(context.Background(), task, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	files, count = branchFiles(t, bare, defaultGitBranch)
+	if !slices.Equal(files, []string{"index.html"}) || count != "2" {
+		t.Fatalf("第二次發布後分支有 %v，%s 個提交", files, count)
+	}
+	if msg := gitOutput(t, bare, "log", "-1", "--format=%s", defaultGitBranch); !strings.Contains(msg, "修改 1，刪除 1") {
+		t.Fatalf("提交說明 = %q", msg)
+	}
+}
+
+// root 指向非空且不是本工具建立的目錄時拒絕發布，目錄內容保持不動。
+func TestPublishGitRefusesForeignRoot(t *testing.T) {
+	tmp := gitTest(t)
+	bare := filepath.Join(tmp, "site.git")
+	gitOutput(t, tmp, "init", "-q", "--bare", bare)
+	src := filepath.Join(tmp, "public")
+	writeFiles(t, src, map[string]string{"index.html": "v1"})
+
+	plain := filepath.Join(tmp, "documents")
+	writeFiles(t, plain, map[string]string{"notes.txt": "keep me"})
+	repo := filepath.Join(tmp, "project")
+	writeFiles(t, repo, map[string]string{"main.go": "package main", "scratch.txt": "untracked"})
+	gitOutput(t, repo, "init", "-q")
+
+	for _, root := range []string{plain, repo} {
+		task := TaskItem{Name: "pages", Type: TaskGit, Src: src, Dst: bare, Root: root}
+		if _, err := publishGit(context.Background(), task, false, nil); err == nil {
+			t.Fatalf("root %s 應被拒絕", root)
+		}
+	}
+	for _, p := range []string{filepath.Join(plain, "notes.txt"), filepath.Join(repo, "main.go"), filepath.Join(repo, "scratch.txt")} {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("%s 被動過: %v", p, err)
+		}
+	}
+}
+
+// 經 http 推送時地址中的密碼只交給憑據助手，不寫進工作區的 .git/config。
+func TestPublishGitHTTPCredentials(t *testing.T) {
+	tmp := gitTest(t)
+	gitBin, _ := exec.LookPath("git")
+	repos := filepath.Join(tmp, "repos")
+	bare := filepath.Join(repos, "site.git")
+	gitOutput(t, tmp, "init", "-q", "--bare", bare)
+	gitOutput(t, bare, "config", "http.receivepack", "true")
+	backend := &cgi.Handler{Path: gitBin, Args: []string{"http-backend"},
+		Env: []string{"GIT_PROJECT_ROOT=" + repos, "GIT_HTTP_EXPORT_ALL=1", "GIT_CONFIG_NOSYSTEM=1"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "deploy" || pass != "p@ss:/#word" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	src := filepath.Join(tmp, "public")
+	writeFiles(t, src, map[string]string{"index.html": "v1"})
+	work := filepath.Join(tmp, "work")
+	dst := strings.Replace(srv.URL, "http://", "http://deploy:p%40ss%3A%2F%23word@", 1) + "/site.git"
+	task := TaskItem{Name: "pages", Type: TaskGit, Src: src, Dst: dst, Root: work}
+	if _, err := publishGit(context.Background(), task, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := branchFiles(t, bare, defaultGitBranch); !slices.Equal(files, []string{"index.html"}) {
+		t.Fatalf("推送後分支有 %v", files)
+	}
+	config, err := os.ReadFile(filepath.Join(work, ".git", "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(config), "deploy") || strings.Contains(string(config), "p%40ss") {
+		t.Fatalf(".git/config 含有憑據:\n%s", config)
+	}
+	if got := gitOutput(t, work, "remote", "get-url", "origin"); got != srv.URL+"/site.git" {
+		t.Fatalf("origin = %s", got)
+	}
+}
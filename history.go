@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// --- 運行歷史 ---
+// 每次運行的參數、結果和完整日誌都存進歷史目錄：index.json 記錄摘要，
+// <id>.log 保存當次的全部狀態消息。超出條數上限時刪掉最舊的記錄。
+
+const (
+	historyDir          = "history"
+	defaultHistoryLimit = 200
+)
+
+type historyEntry struct {
+	ID      string    `json:"id"`
+	Trigger string    `json:"trigger"` // 觸發來源，如「全部分組」「計劃 nightly」
+	Profile string    `json:"profile"`
+	Order   string    `json:"order"`
+	Names   []string  `json:"names,omitempty"`
+	Force   bool      `json:"force,omitempty"`
+	Report  runReport `json:"report"`
+}
+
+func historyIndexPath() string { return filepath.Join(historyDir, "index.json") }
+
+func historyLogPath(id string) string { return filepath.Join(historyDir, id+".log") }
+
+func historyLimit(c Config) int {
+	if c.HistoryLimit > 0 {
+		return c.HistoryLimit
+	}
+	return defaultHistoryLimit
+}
+
+// loadHistory 讀取歷史記錄，按時間從舊到新排列。
+func loadHistory() ([]historyEntry, error) {
+	var entries []historyEntry
+	data, err := os.ReadFile(historyIndexPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entries, json.Unmarshal(data, &entries)
+}
+
+// recordHistory 把一次運行及其日誌寫入歷史，並按上限輪換。
+// 多個進程可能同時寫，索引的讀改寫在文件鎖內完成。
+func recordHistory(c Config, e historyEntry, log []string) error {
+	if err := os.MkdirAll(historyDir, 0755); err != nil {
+		return err
+	}
+	var text strings.Builder
+	for _, line := range log {
+		text.WriteString(redactSecrets(line))
+		text.WriteByte('\n')
+	}
+	if err := os.WriteFile(historyLogPath(e.ID), []byte(text.String()), 0644); err != nil {
+		return err
+	}
+
+	unlock, err := lockPath(context.Background(), historyIndexPath()+".lock", nil)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	entries, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	entries = append(entries, e)
+	if limit := historyLimit(c); len(entries) > limit {
+		for _, old := range entries[:len(entries)-limit] {
+			_ = os.Remove(historyLogPath(old.ID))
+		}
+		entries = entries[len(entries)-limit:]
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(historyIndexPath(), data, 0644)
+}
+
+// newHistoryID 以開始時間和配置名生成記錄 ID，精確到毫秒以免連續運行重名。
+func newHistoryID(profile string, start time.Time) string {
+	return fmt.Sprintf("%s-%s", start.Format("20060102-150405.000"), profile)
+}
+
+// latestHistoryLog 返回最近一次運行的日誌路徑。
+func latestHistoryLog() (string, error) {
+	entries, err := loadHistory()
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("還沒有運行記錄")
+	}
+	return historyLogPath(entries[len(entries)-1].ID), nil
+}
+
+// readHistoryLog 讀取某次運行的完整日誌。
+func readHistoryLog(id string) (string, error) {
+	data, err := os.ReadFile(historyLogPath(id))
+	return string(data), err
+}
+
+// matches 判斷記錄是否符合篩選條件：配置名、狀態和任意文字（觸發來源或任務名）。
+func (e historyEntry) matches(profile, status, text string) bool {
+	if profile != "" && e.Profile != profile {
+		return false
+	}
+	if status != "" && e.Report.Status != status {
+		return false
+	}
+	if text == "" {
+		return true
+	}
+	text = strings.ToLower(text)
+	if strings.Contains(strings.ToLower(e.Trigger), text) {
+		return true
+	}
+	for _, t := range e.Report.Tasks {
+		if strings.Contains(strings.ToLower(t.Name), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- 運行歷史界面 ---
+
+// showHistoryWindow 打開運行歷史窗口，可按配置、狀態和文字篩選，
+// 查看每次運行的任務結果和完整日誌，並以相同參數重新運行。
+func showHistoryWindow(a fyne.App, rerun func(e historyEntry), openLog func(id string)) {
+	w := a.NewWindow("運行歷史")
+	w.Resize(fyne.NewSize(900, 600))
+
+	var all, shown []historyEntry
+	selected := -1
+
+	profileSelect := widget.NewSelect(append([]string{"全部配置"}, listProfiles()...), nil)
+	profileSelect.SetSelected("全部配置")
+	statusSelect := widget.NewSelect([]string{"全部狀態", "success", "failed", "cancelled"}, nil)
+	statusSelect.SetSelected("全部狀態")
+	textEntry := widget.NewEntry()
+	textEntry.SetPlaceHolder("觸發來源或任務名")
+
+	list := widget.NewList(
+		func() int { return len(shown) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			e := shown[i]
+			o.(*widget.Label).SetText(fmt.Sprintf("%s  %s  %s  %s",
+				e.Report.Start.Format("01-02 15:04:05"), e.Profile, e.Report.Status, e.Trigger))
+		},
+	)
+	detail := widget.NewLabel("")
+	detail.Wrapping = fyne.TextWrapWord
+	logView := widget.NewLabel("")
+	logView.Wrapping = fyne.TextWrapBreak
+	logView.TextStyle = fyne.TextStyle{Monospace: true}
+
+	rerunBtn := widget.NewButtonWithIcon("重新運行", theme.MediaReplayIcon(), func() {
+		if selected >= 0 {
+			rerun(shown[selected])
+		}
+	})
+	openBtn := widget.NewButtonWithIcon("打開日誌文件", theme.FileTextIcon(), func() {
+		if selected >= 0 {
+			openLog(shown[selected].ID)
+		}
+	})
+	rerunBtn.Disable()
+	openBtn.Disable()
+
+	list.OnSelected = func(i widget.ListItemID) {
+		selected = i
+		e := shown[i]
+		var b strings.Builder
+		rep := e.Report
+		fmt.Fprintf(&b, "%s  觸發: %s  配置: %s  狀態: %s  耗時 %s\n分組順序: %s",
+			rep.Start.Format("2006-01-02 15:04:05"), e.Trigger, e.Profile, rep.Status,
+			time.Duration(rep.DurationMs)*time.Millisecond, e.Order)
+		if len(e.Names) > 0 {
+			fmt.Fprintf(&b, "  任務: %s", strings.Join(e.Names, ", "))
+		}
+		for _, t := range rep.Tasks {
+			fmt.Fprintf(&b, "\n  [%s] %s", t.Name, t.Status)
+			if t.Type.copiesFiles() {
+				fmt.Fprintf(&b, "  複製 %d  跳過 %d  刪除 %d", t.Copied, t.Skipped, t.Deleted)
+			}
+			if t.Error != "" {
+				fmt.Fprintf(&b, "  %s", t.Error)
+			}
+		}
+		detail.SetText(b.String())
+		text, err := readHistoryLog(e.ID)
+		if err != nil {
+			text = "日誌不可用: " + err.Error()
+		}
+		logView.SetText(text)
+		rerunBtn.Enable()
+		openBtn.Enable()
+	}
+
+	filter := func() {
+		profile, status := profileSelect.Selected, statusSelect.Selected
+		if profile == "全部配置" {
+			profile = ""
+		}
+		if status == "全部狀態" {
+			status = ""
+		}
+		shown = nil
+		for i := len(all) - 1; i >= 0; i-- {
+			if all[i].matches(profile, status, strings.TrimSpace(textEntry.Text)) {
+				shown = append(shown, all[i])
+			}
+		}
+		selected = -1
+		list.UnselectAll()
+		list.Refresh()
+		detail.SetText("")
+		logView.SetText("")
+		rerunBtn.Disable()
+		openBtn.Disable()
+	}
+	reload := func() {
+		var err error
+		if all, err = loadHistory(); err != nil {
+			dialog.ShowError(err, w)
+		}
+		filter()
+	}
+	profileSelect.OnChanged = func(string) { filter() }
+	statusSelect.OnChanged = func(string) { filter() }
+	textEntry.OnChanged = func(string) { filter() }
+
+	filters := container.NewBorder(nil, nil,
+		container.NewHBox(profileSelect, statusSelect),
+		widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), reload),
+		textEntry,
+	)
+	right := container.NewBorder(
+		container.NewVBox(detail, container.NewHBox(rerunBtn, openBtn), widget.NewSeparator()),
+		nil, nil, nil,
+		container.NewVScroll(logView),
+	)
+	split := container.NewHSplit(list, right)
+	split.Offset = 0.4
+	w.SetContent(container.NewBorder(container.NewPadded(filters), nil, nil, nil, split))
+	reload()
+	w.Show()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// --- 增量複製與鏡像刪除 ---
+// 非強制模式下，目標中與源文件一致的文件不再複製：大小相同且修改時間相差不到一秒，
+// 或由後端按內容判斷（見 contentComparer）。強制覆蓋模式總是全部複製。
+// 任務開啟 Mirror 時，全部複製成功後再刪除目標中源端沒有的條目，被過濾排除的路徑不受影響。
+
+// unchanged 判斷目標文件是否無需重新複製，後端能按內容比較時優先按內容。
+func unchanged(ctx context.Context, src, dst storageBackend, s, d fileEntry) bool {
+	if c, ok := dst.(contentComparer); ok {
+		same, err := c.Same(ctx, src, s, d)
+		return err == nil && same
+	}
+	return sameEntry(s, d)
+}
+
+// sameEntry 判斷目標文件是否與源文件一致：大小相同且修改時間相差不到一秒。
+// 不少遠程存儲只保留到秒，精確比較會導致每次都重新上傳。
+func sameEntry(src, dst fileEntry) bool {
+	if dst.IsDir || src.Size != dst.Size {
+		return false
+	}
+	d := src.ModTime.Sub(dst.ModTime)
+	return d > -time.Second && d < time.Second
+}
+
+// mirrorDelete 刪除目標端不在源端的條目，不參與同步的路徑在掃描時已跳過。源端讀取出錯時不會調用，避免誤刪。
+func mirrorDelete(ctx context.Context, dst storageBackend, plan syncPlan, opt syncOptions, stats *syncStats) error {
+	var dirs []string
+	for _, e := range plan.target {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if plan.seen[e.Path] {
+			continue
+		}
+		if e.IsDir {
+			dirs = append(dirs, e.Path)
+			continue
+		}
+		publish(FileDeleted{Task: opt.Task, Path: e.Path})
+		if err := dst.Remove(ctx, e.Path); err != nil {
+			stats.fail(err)
+			continue
+		}
+		stats.Deleted++
+	}
+	// 列表先父後子，倒序刪除才能先清空子目錄；
+	// 仍有被排除文件的目錄保留
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, err := dst.List(ctx, dirs[i]); err != nil || len(entries) > 0 {
+			continue
+		}
+		if err := dst.Remove(ctx, dirs[i]); err != nil {
+			stats.fail(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// --- 跨進程鎖 ---
+// 按寫入目標加鎖：同步和歸檔的目標、GIT 任務的工作區和遠程分支、命令任務的工作目錄，
+// 規範化後各對應系統臨時目錄下的一個 .lock 文件，運行期間持有獨佔鎖。
+// 不同窗口、不同配置或計劃任務寫同一目標時依次進行，目標互不相干的運行照常並行。
+
+var targetLockDir = filepath.Join(os.TempDir(), "hugo-sync-locks")
+
+// writeTargets 返回任務會寫入的目標，已規範化、去重並排序，按同一順序加鎖不會互相死鎖。
+func writeTargets(tasks []TaskItem) []string {
+	var out []string
+	add := func(s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, normalizeTarget(s))
+		}
+	}
+	for _, raw := range tasks {
+		t := raw
+		if e, err := expandTask(raw); err == nil {
+			t = e
+		}
+		switch t.Type {
+		case TaskSync, TaskArchive:
+			add(t.Dst)
+		case TaskCmd:
+			add(t.Root)
+		case TaskGit:
+			add(gitWorktree(t))
+			// 遠程按配置原文區分，展開後的地址可能帶令牌
+			if branch := cmp.Or(t.Branch, defaultGitBranch); raw.Dst != "" {
+				out = append(out, "git:"+raw.Dst+"#"+branch)
+			}
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// normalizeTarget 把同一目標的不同寫法統一：本地路徑取絕對路徑（Windows 不分大小寫），
+// 地址忽略用戶信息和參數，只保留協議、主機、路徑和 S3 的 endpoint。
+func normalizeTarget(endpoint string) string {
+	if dir, ok := localPath(endpoint); ok {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		dir = filepath.Clean(dir)
+		if runtime.GOOS == "windows" {
+			dir = strings.ToLower(dir)
+		}
+		return "file:" + dir
+	}
+	u, err := parseEndpoint(endpoint)
+	if err != nil || u == nil {
+		return endpoint
+	}
+	key := strings.ToLower(u.Scheme+"://"+u.Host) + path.Clean("/"+u.Path)
+	if e := u.Query().Get("endpoint"); e != "" {
+		key += "?endpoint=" + strings.ToLower(e)
+	}
+	return key
+}
+
+// targetLockPath 以目標的哈希命名鎖文件，地址中的字符不會出現在文件名裡。
+func targetLockPath(target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return filepath.Join(targetLockDir, hex.EncodeToString(sum[:12])+".lock")
+}
+
+// lockTargets 獲取任務所有寫入目標的跨進程鎖，被其他進程佔用時每秒重試，直到全部拿到或 ctx 取消。
+// 進程退出時系統會自動釋放鎖。
+func lockTargets(ctx context.Context, tasks []TaskItem) (unlock func(), err error) {
+	if err := os.MkdirAll(targetLockDir, 0755); err != nil {
+		return nil, err
+	}
+	var unlocks []func()
+	release := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+	for _, target := range writeTargets(tasks) {
+		u, err := lockPath(ctx, targetLockPath(target), func() {
+			postStatus("目標 " + redactEndpoint(target) + " 正由其他進程寫入，等待其結束...")
+		})
+		if err != nil {
+			release()
+			return nil, err
+		}
+		unlocks = append(unlocks, u)
+	}
+	return release, nil
+}
+
+// lockPath 獲取 path 上的獨佔鎖，第一次需要等待時調用 onWait。
+func lockPath(ctx context.Context, path string, onWait func()) (unlock func(), err error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	waiting := false
+	for {
+		ok, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if ok {
+			return func() { f.Close() }, nil
+		}
+		if !waiting && onWait != nil {
+			onWait()
+		}
+		waiting = true
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
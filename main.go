@@ -1,20 +1,22 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
-	"syscall"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
@@ -23,11 +25,20 @@ import (
 type TaskType string
 
 const (
-	TaskSync TaskType = "SYNC"
-	TaskCmd  TaskType = "CMD"
+	TaskSync    TaskType = "SYNC"
+	TaskCmd     TaskType = "CMD"
+	TaskGit     TaskType = "GIT"     // 同步到 git 倉庫的分支並推送，見 publishGit
+	TaskArchive TaskType = "ARCHIVE" // 打包成 zip 或 tar.gz，見 buildArchive
 )
 
+// copiesFiles 表示該類任務會同步文件，報告中帶有文件統計。
+func (t TaskType) copiesFiles() bool {
+	return t == TaskSync || t == TaskGit || t == TaskArchive
+}
+
 type TaskItem struct {
+	Name    string   `json:"name"`
+	Enabled bool     `json:"enabled"`
 	Type    TaskType `json:"type"`
 	GroupID int      `json:"group_id"`
 	Src     string   `json:"src"`
@@ -35,20 +46,49 @@ type TaskItem struct {
 	Root    string   `json:"root"`
 	Cmd     string   `json:"cmd"`
 	Desc    string   `json:"desc"`
+	Mirror  bool     `json:"mirror,omitempty"`
+	Include string   `json:"include,omitempty"` // 逗號分隔的通配符，見 taskFilter
+	Exclude string   `json:"exclude,omitempty"`
+	Branch  string   `json:"branch,omitempty"`  // GIT 任務的目標分支，默認 gh-pages
+	Message string   `json:"message,omitempty"` // GIT 任務的提交說明模板，見 publishGit
+	Archive string   `json:"archive,omitempty"` // ARCHIVE 任務的文件名模板
+	Keep    int      `json:"keep,omitempty"`    // ARCHIVE 任務保留的歸檔數，0 表示不清理
+	Workers int      `json:"workers,omitempty"` // 同步時並行傳輸的文件數，0 為默認，見 syncWorkers
+}
+
+// UnmarshalJSON 讓舊配置中沒有 enabled 字段的任務默認啟用。
+func (t *TaskItem) UnmarshalJSON(data []byte) error {
+	type plain TaskItem
+	p := plain{Enabled: true}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*t = TaskItem(p)
+	return nil
 }
 
 type Config struct {
-	Tasks      []TaskItem `json:"tasks"`
-	GroupOrder string     `json:"group_order"`
-	ForceCopy  bool       `json:"force_copy"`
+	Tasks      []TaskItem   `json:"tasks"`
+	GroupOrder string       `json:"group_order"`
+	ForceCopy  bool         `json:"force_copy"`
+	Watch      WatchConfig  `json:"watch"`
+	Schedules  []Schedule   `json:"schedules,omitempty"`
+	API        APIConfig    `json:"api,omitzero"`
+	Notify     NotifyConfig `json:"notify,omitzero"`
+
+	ReportDir   string `json:"report_dir,omitempty"`   // 運行報告目錄，默認 reports
+	JUnitReport bool   `json:"junit_report,omitempty"` // 同時輸出 JUnit XML
+
+	HistoryLimit int `json:"history_limit,omitempty"` // 運行歷史保留條數，默認 200
 }
 
-var (
-	configPath = "sync_config_v4.json"
-	statusChan = make(chan string, 100)
-)
+var configPath = "sync_config_v4.json"
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	myApp := app.New()
 	window := myApp.NewWindow("Hugo 任務編組工具 V4.9 (UI 穩定版)")
 
@@ -62,130 +102,486 @@ func main() {
 	statusLabel := widget.NewLabel("準備就緒")
 	statusLabel.Alignment = fyne.TextAlignCenter
 	statusLabel.Wrapping = fyne.TextWrapBreak // 自動換行，不再顯示 ...
+	uiEvents, _ := events.Subscribe(256)
 
+	model := newTaskModel(conf)
 	taskListContainer := container.NewVBox()
 
+	// --- 運行控制 ---
+	// 整體執行、單任務、單組執行以及監視、計劃和接口觸發都提交到同一個運行隊列，
+	// 同時只跑一次，其餘排隊或與相同的排隊請求合併。
+	var runs *runQueue
+	var stopBtn, clearQueueBtn *widget.Button
+	var tray *systemTray
+	var last *runResult
+	queueLabel := widget.NewLabel("")
+
+	// 進度條：當前任務和整體，文字顯示吞吐量和預計剩餘時間
+	progressTask := ""
+	taskBar := widget.NewProgressBar()
+	taskBar.TextFormatter = func() string {
+		return fmt.Sprintf("%s %.0f%%", progressTask, taskBar.Value*100)
+	}
+	overallBar := widget.NewProgressBar()
+	progressLabel := widget.NewLabel("")
+
+	runs = newRunQueue(func(ctx context.Context, r *runRequest) runResult {
+		// 記錄當前尺寸
+		var currentSize fyne.Size
+		fyne.DoAndWait(func() {
+			currentSize = window.Canvas().Size()
+			progressTask = ""
+			taskBar.SetValue(0)
+			overallBar.SetValue(0)
+			progressLabel.SetText("")
+		})
+
+		res := executeRun(ctx, r.Title, r.Profile, r.Config, r.Order, r.Names, r.Config.ForceCopy)
+		if n := runNotification(r.Config.Notify, res); n != nil {
+			myApp.SendNotification(n)
+		}
+
+		// 3. 精準刷新並鎖死尺寸
+		time.Sleep(200 * time.Millisecond)
+		fyne.Do(func() {
+			last = &res
+			window.Content().Refresh()
+			window.Resize(currentSize)
+		})
+		return res
+	}, func() {
+		fyne.Do(func() {
+			queueLabel.SetText(runs.Describe())
+			if tray != nil {
+				switch {
+				case runs.Busy():
+					tray.SetState(runs.Describe(), true, false)
+				case last != nil:
+					tray.SetState("上次: "+last.Summary(), false, last.Status() == "failed")
+				}
+			}
+			if runs.Busy() {
+				stopBtn.Enable()
+			} else {
+				stopBtn.Disable()
+			}
+			if runs.Pending() > 0 {
+				clearQueueBtn.Enable()
+			} else {
+				clearQueueBtn.Disable()
+			}
+		})
+	})
+	runNow := func(title string, c Config, order string, names []string) {
+		if dup := duplicateTaskNames(c.Tasks); len(dup) > 0 {
+			dialog.ShowError(fmt.Errorf("任務名重複: %s，請先修改再運行", strings.Join(dup, "、")), window)
+			return
+		}
+		runs.Submit(context.Background(), &runRequest{Title: title, Profile: defaultProfile, Config: c, Order: order, Names: names})
+	}
+	runTaskNow := func(t *TaskItem) {
+		c := model.Config()
+		i := slices.Index(model.Items(), t)
+		if i < 0 {
+			return // 回調前該行的任務已被刪除或替換
+		}
+		name := c.Tasks[i].Name
+		runNow("任務 "+name, c, fmt.Sprint(t.GroupID), []string{name})
+	}
+	runGroupNow := func(id int) {
+		runNow(fmt.Sprintf("分組 %d", id), model.Config(), fmt.Sprint(id), nil)
+	}
+
+	// queuedRun 供監視和計劃觸發使用：按觸發時的配置快照排隊並等到運行結束，
+	// pick 決定分組順序和任務名。ctx 取消時不再等待，排隊中的請求隨之作廢。
+	queuedRun := func(ctx context.Context, title string, pick func(c Config) (string, []string)) runResult {
+		var c Config
+		fyne.DoAndWait(func() { c = model.Config() })
+		order, names := pick(c)
+		select {
+		case res := <-runs.Submit(ctx, &runRequest{Title: title, Profile: defaultProfile, Config: c, Order: order, Names: names}):
+			return res
+		case <-ctx.Done():
+			return runResult{Profile: defaultProfile, Cancelled: true}
+		}
+	}
+
+	// profileConfig 返回配置的當前內容：默認配置取編輯中的模型，其他配置從磁盤讀取。
+	// 供後台協程調用，讀模型時切到主線程，避免與綁定的寫入競爭。
+	profileConfig := func(profile string) (Config, error) {
+		if profile != defaultProfile {
+			return loadProfile(profile)
+		}
+		var c Config
+		fyne.DoAndWait(func() { c = model.Config() })
+		return c, nil
+	}
+	// rerun 以歷史記錄中的參數和配置的當前內容重新運行。
+	rerun := func(e historyEntry) {
+		go func() {
+			c, err := profileConfig(e.Profile)
+			if err != nil {
+				postStatus("讀取配置失敗: " + err.Error())
+				return
+			}
+			c.ForceCopy = e.Force
+			runs.Submit(context.Background(), &runRequest{Title: "重跑 " + e.Trigger, Profile: e.Profile, Config: c, Order: e.Order, Names: e.Names})
+		}()
+	}
+	openFile := func(path string) {
+		u, err := fileURL(path)
+		if err == nil {
+			err = myApp.OpenURL(u)
+		}
+		if err != nil {
+			postStatus("無法打開文件: " + err.Error())
+		}
+	}
+
+	// --- 監視模式 ---
+	var stopWatch context.CancelFunc
+	watchRun := func(ctx context.Context, names []string) runResult {
+		return queuedRun(ctx, "監視觸發", func(c Config) (string, []string) { return c.GroupOrder, names })
+	}
+	var watchCheck *widget.Check
+	watchCheck = widget.NewCheck("監視模式", func(on bool) {
+		if !on {
+			if stopWatch != nil {
+				stopWatch()
+				stopWatch = nil
+				postStatus("已停止監視")
+			}
+			return
+		}
+		if stopWatch != nil {
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		stopWatch = cancel
+		c := model.Config()
+		go func() {
+			if err := watchAndRun(ctx, c, watchRun); err != nil {
+				postStatus("無法開始監視: " + err.Error())
+				fyne.Do(func() { watchCheck.SetChecked(false) })
+			}
+		}()
+	})
+
+	// --- 計劃任務 ---
+	// 窗口打開期間按默認配置中的計劃觸發；編輯計劃後重啟調度。
+	nextLabel := widget.NewLabel("")
+	var stopSchedule context.CancelFunc
+	// 計劃觸發的運行有自己的上下文，不隨調度循環取消：修改配置重啟調度時，
+	// 已提交的運行照常跑完，舊的調度循環記下結果後退出。
+	scheduleRun := func(_ context.Context, s Schedule) runResult {
+		return queuedRun(context.Background(), "計劃 "+s.Name, func(c Config) (string, []string) { return s.order(c), s.taskNames() })
+	}
+	restartScheduler := func() {
+		if stopSchedule != nil {
+			stopSchedule()
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		stopSchedule = cancel
+		go runScheduler(ctx, defaultProfile, model.Config(), scheduleRun, func(name string, at time.Time) {
+			fyne.Do(func() {
+				if ctx.Err() != nil {
+					return
+				}
+				if at.IsZero() {
+					nextLabel.SetText("")
+					return
+				}
+				nextLabel.SetText(fmt.Sprintf("下次計劃: %s %s", name, at.Format("01-02 15:04")))
+			})
+		})
+	}
+	restartScheduler()
+
+	// --- HTTP 控制接口 ---
+	// 默認配置取當前編輯中的內容，其他配置從磁盤讀取；運行同樣進入運行隊列。
+	if conf.API.Enabled {
+		go func() {
+			if err := serveAPI(context.Background(), conf.API, profileConfig, runs.Submit); err != nil {
+				postStatus("無法啟動 HTTP 接口: " + err.Error())
+			}
+		}()
+	}
+
 	// --- 任務行創建函數 ---
-	var createSyncRow func(TaskItem) fyne.CanvasObject
-	createSyncRow = func(t TaskItem) fyne.CanvasObject {
-		groupEntry := widget.NewEntry()
-		groupEntry.SetText(fmt.Sprintf("%d", t.GroupID))
-		srcEntry := widget.NewEntry()
-		srcEntry.SetText(t.Src)
-		dstEntry := widget.NewEntry()
-		dstEntry.SetText(t.Dst)
-		var wrapper *fyne.Container
-		removeBtn := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
-			taskListContainer.Remove(wrapper)
-			taskListContainer.Refresh()
+	folderButton := func(target binding.String) fyne.CanvasObject {
+		return widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
+			dialog.ShowFolderOpen(func(list fyne.ListableURI, err error) {
+				if list != nil {
+					_ = target.Set(list.Path())
+				}
+			}, window)
 		})
+	}
+
+	rowHeader := func(t *TaskItem, f taskFields, kind string) fyne.CanvasObject {
+		nameEntry := widget.NewEntryWithData(f.Name)
+		nameEntry.SetPlaceHolder("任務名")
+		nameEntry.Validator = func(name string) error {
+			for _, o := range model.Items() {
+				if o != t && name != "" && o.Name == name {
+					return errors.New("任務名重複")
+				}
+			}
+			return nil
+		}
+		return container.NewHBox(widget.NewCheckWithData("", f.Enabled), nameEntry, widget.NewLabel(kind))
+	}
+
+	createSyncRow := func(t *TaskItem) fyne.CanvasObject {
+		f := bindTask(t)
+		descEntry := widget.NewEntryWithData(f.Desc)
+		descEntry.SetPlaceHolder("備註")
+		mirrorCheck := widget.NewCheckWithData("鏡像刪除", f.Mirror)
+		includeEntry := widget.NewEntryWithData(f.Include)
+		includeEntry.SetPlaceHolder("只包含，如 *.html, *.css")
+		excludeEntry := widget.NewEntryWithData(f.Exclude)
+		excludeEntry.SetPlaceHolder("排除，如 .git, *.tmp")
+		workersEntry := widget.NewEntryWithData(f.Workers)
+		workersEntry.SetPlaceHolder(fmt.Sprintf("並行數（默認 %d）", defaultWorkers))
 		innerRow := container.NewVBox(
-			container.NewHBox(widget.NewLabel("分組ID:"), groupEntry, widget.NewLabel("【同步任務】")),
+			rowHeader(t, f, "【同步任務】"),
 			container.NewGridWithColumns(2,
-				container.NewBorder(nil, nil, nil, widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
-					dialog.ShowFolderOpen(func(list fyne.ListableURI, err error) {
-						if list != nil {
-							srcEntry.SetText(list.Path())
-						}
-					}, window)
-				}), srcEntry),
-				container.NewBorder(nil, nil, nil, widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
-					dialog.ShowFolderOpen(func(list fyne.ListableURI, err error) {
-						if list != nil {
-							dstEntry.SetText(list.Path())
-						}
-					}, window)
-				}), dstEntry),
+				container.NewBorder(nil, nil, nil, folderButton(f.Src), widget.NewEntryWithData(f.Src)),
+				container.NewBorder(nil, nil, nil, folderButton(f.Dst), widget.NewEntryWithData(f.Dst)),
 			),
-			container.NewHBox(widget.NewSeparator(), removeBtn),
+			container.NewGridWithColumns(2, includeEntry, excludeEntry),
+			container.NewBorder(nil, nil, nil, container.NewHBox(
+				widget.NewButtonWithIcon("", theme.MediaPlayIcon(), func() { runTaskNow(t) }),
+				widget.NewButtonWithIcon("", theme.DeleteIcon(), func() { model.Remove(t) }),
+			), container.NewBorder(nil, nil, mirrorCheck, workersEntry, descEntry)),
 		)
-		wrapper = container.NewPadded(innerRow)
-		return wrapper
-	}
-
-	var createCmdRow func(TaskItem) fyne.CanvasObject
-	createCmdRow = func(t TaskItem) fyne.CanvasObject {
-		groupEntry := widget.NewEntry()
-		groupEntry.SetText(fmt.Sprintf("%d", t.GroupID))
-		rootEntry := widget.NewEntry()
-		rootEntry.SetText(t.Root)
-		cmdEntry := widget.NewEntry()
-		cmdEntry.SetText(t.Cmd)
-		descEntry := widget.NewEntry()
-		descEntry.SetText(t.Desc)
-		var wrapper *fyne.Container
-		removeBtn := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
-			taskListContainer.Remove(wrapper)
-			taskListContainer.Refresh()
-		})
+		return container.NewPadded(innerRow)
+	}
+
+	createCmdRow := func(t *TaskItem) fyne.CanvasObject {
+		f := bindTask(t)
+		innerRow := container.NewVBox(
+			rowHeader(t, f, "【腳本命令】"),
+			container.NewGridWithColumns(3, widget.NewEntryWithData(f.Root), widget.NewEntryWithData(f.Cmd), widget.NewEntryWithData(f.Desc)),
+			container.NewHBox(
+				widget.NewLabel("根目錄 / 執行命令 / 按鈕名"),
+				widget.NewButtonWithIcon("手動執行", theme.MediaPlayIcon(), func() { runTaskNow(t) }),
+				widget.NewButtonWithIcon("", theme.DeleteIcon(), func() { model.Remove(t) }),
+			),
+		)
+		return container.NewPadded(innerRow)
+	}
+
+	createGitRow := func(t *TaskItem) fyne.CanvasObject {
+		f := bindTask(t)
+		remoteEntry := widget.NewEntryWithData(f.Dst)
+		remoteEntry.SetPlaceHolder("遠程倉庫，如 git@github.com:me/me.github.io.git")
+		branchEntry := widget.NewEntryWithData(f.Branch)
+		branchEntry.SetPlaceHolder(defaultGitBranch)
+		messageEntry := widget.NewEntryWithData(f.Message)
+		messageEntry.SetPlaceHolder("提交說明，可用 {changed} {added} {modified} {deleted} {time}")
+		rootEntry := widget.NewEntryWithData(f.Root)
+		rootEntry.SetPlaceHolder("本地工作區，默認 git-publish/任務名")
+		includeEntry := widget.NewEntryWithData(f.Include)
+		includeEntry.SetPlaceHolder("只包含，如 *.html, *.css")
+		excludeEntry := widget.NewEntryWithData(f.Exclude)
+		excludeEntry.SetPlaceHolder("排除（目標中保留），如 CNAME, .nojekyll")
+		descEntry := widget.NewEntryWithData(f.Desc)
+		descEntry.SetPlaceHolder("備註")
+		innerRow := container.NewVBox(
+			rowHeader(t, f, "【Git 發布】"),
+			container.NewGridWithColumns(2,
+				container.NewBorder(nil, nil, nil, folderButton(f.Src), widget.NewEntryWithData(f.Src)),
+				container.NewBorder(nil, nil, nil, branchEntry, remoteEntry),
+			),
+			container.NewGridWithColumns(2, messageEntry, rootEntry),
+			container.NewGridWithColumns(2, includeEntry, excludeEntry),
+			container.NewBorder(nil, nil, nil, container.NewHBox(
+				widget.NewButtonWithIcon("", theme.MediaPlayIcon(), func() { runTaskNow(t) }),
+				widget.NewButtonWithIcon("", theme.DeleteIcon(), func() { model.Remove(t) }),
+			), descEntry),
+		)
+		return container.NewPadded(innerRow)
+	}
+
+	createArchiveRow := func(t *TaskItem) fyne.CanvasObject {
+		f := bindTask(t)
+		archiveEntry := widget.NewEntryWithData(f.Archive)
+		archiveEntry.SetPlaceHolder(defaultArchiveName + "，可用 {task} {date} {time}")
+		keepEntry := widget.NewEntryWithData(f.Keep)
+		keepEntry.SetPlaceHolder("保留個數")
+		includeEntry := widget.NewEntryWithData(f.Include)
+		includeEntry.SetPlaceHolder("只包含，如 *.html, *.css")
+		excludeEntry := widget.NewEntryWithData(f.Exclude)
+		excludeEntry.SetPlaceHolder("排除，如 .git, *.tmp")
+		descEntry := widget.NewEntryWithData(f.Desc)
+		descEntry.SetPlaceHolder("備註")
 		innerRow := container.NewVBox(
-			container.NewHBox(widget.NewLabel("分組ID:"), groupEntry, widget.NewLabel("【腳本命令】")),
-			container.NewGridWithColumns(3, rootEntry, cmdEntry, descEntry),
-			container.NewHBox(widget.NewLabel("根目錄 / 執行命令 / 按鈕名"), removeBtn),
+			rowHeader(t, f, "【打包歸檔】"),
+			container.NewGridWithColumns(2,
+				container.NewBorder(nil, nil, nil, folderButton(f.Src), widget.NewEntryWithData(f.Src)),
+				container.NewBorder(nil, nil, nil, folderButton(f.Dst), widget.NewEntryWithData(f.Dst)),
+			),
+			container.NewGridWithColumns(2, archiveEntry, keepEntry),
+			container.NewGridWithColumns(2, includeEntry, excludeEntry),
+			container.NewBorder(nil, nil, nil, container.NewHBox(
+				widget.NewButtonWithIcon("", theme.MediaPlayIcon(), func() { runTaskNow(t) }),
+				widget.NewButtonWithIcon("", theme.DeleteIcon(), func() { model.Remove(t) }),
+			), descEntry),
 		)
-		wrapper = container.NewPadded(innerRow)
-		return wrapper
+		return container.NewPadded(innerRow)
 	}
 
-	for _, t := range conf.Tasks {
-		if t.Type == TaskSync {
-			taskListContainer.Add(createSyncRow(t))
-		} else {
-			taskListContainer.Add(createCmdRow(t))
+	// --- 分組列表與拖動排序 ---
+	// slots 與 taskListContainer.Objects 一一對應，記錄每個控件代表的分組或任務，
+	// 拖動結束時據此把鬆手位置換算成落點。
+	type listSlot struct {
+		group int
+		task  *TaskItem // 為 nil 時代表分組標題
+		end   bool      // 列表末尾之下的空白處
+	}
+	var slots []listSlot
+	var rebuildList func()
+	collapsed := map[int]bool{}
+
+	slotAt := func(pos fyne.Position) (listSlot, bool) {
+		driver := fyne.CurrentApp().Driver()
+		for i, obj := range taskListContainer.Objects {
+			top := driver.AbsolutePositionForObject(obj).Y
+			if pos.Y >= top && pos.Y < top+obj.Size().Height {
+				return slots[i], true
+			}
 		}
+		if len(slots) > 0 && pos.Y >= driver.AbsolutePositionForObject(taskListContainer).Y {
+			return listSlot{group: slots[len(slots)-1].group, end: true}, true
+		}
+		return listSlot{}, false
 	}
 
-	// --- 底部控制區 ---
-	orderEntry := widget.NewEntry()
-	orderEntry.SetText(conf.GroupOrder)
-	forceCheck := widget.NewCheck("強制覆蓋模式", nil)
-	forceCheck.Checked = conf.ForceCopy
-
-	var syncBtn *widget.Button
-	syncBtn = widget.NewButtonWithIcon("🔥 開始按順序執行", theme.MediaPlayIcon(), func() {
-		syncBtn.Disable()
-		go func() {
-			defer syncBtn.Enable()
+	dropTask := func(t *TaskItem) func(fyne.Position) {
+		return func(pos fyne.Position) {
+			s, ok := slotAt(pos)
+			if !ok {
+				return
+			}
+			before := s.task
+			if before == nil && !s.end {
+				// 落在分組標題上時放到該組最前面
+				if first := model.TasksIn(s.group); len(first) > 0 {
+					before = first[0]
+				}
+			}
+			model.MoveTask(t, s.group, before)
+		}
+	}
 
-			// 記錄當前尺寸
-			currentSize := window.Canvas().Size()
+	dropGroup := func(id int) func(fyne.Position) {
+		return func(pos fyne.Position) {
+			if s, ok := slotAt(pos); ok && s.end {
+				model.MoveGroup(id, -1)
+			} else if ok {
+				model.MoveGroup(id, s.group)
+			}
+		}
+	}
 
-			tasks := collectAllTasks(taskListContainer)
-			orders := strings.Split(orderEntry.Text, ",")
+	createGroupHeader := func(id int) fyne.CanvasObject {
+		tasks := model.TasksIn(id)
+		icon := theme.MenuDropDownIcon()
+		if collapsed[id] {
+			icon = theme.MenuExpandIcon()
+		}
+		var toggle *widget.Button
+		toggle = widget.NewButtonWithIcon("", icon, func() {
+			collapsed[id] = !collapsed[id]
+			rebuildList()
+		})
+		toggle.Importance = widget.LowImportance
+		title := widget.NewLabelWithStyle(fmt.Sprintf("分組 %d（%d 個任務）", id, len(tasks)), fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+		right := container.NewHBox(widget.NewButtonWithIcon("執行本組", theme.MediaPlayIcon(), func() { runGroupNow(id) }))
+		if len(tasks) == 0 {
+			right.Add(widget.NewButtonWithIcon("", theme.DeleteIcon(), func() { model.RemoveGroup(id) }))
+		}
+		return container.NewBorder(nil, nil, container.NewHBox(newDragHandle(dropGroup(id)), toggle), right, title)
+	}
 
-			for _, gID := range orders {
-				gID = strings.TrimSpace(gID)
-				if gID == "" {
-					continue
-				}
-				statusChan <- "正在運行組: " + gID
-				for _, t := range tasks {
-					if fmt.Sprintf("%d", t.GroupID) == gID {
-						if t.Type == TaskSync {
-							fullSync(t.Src, t.Dst, forceCheck.Checked)
-						} else {
-							executeCommand(t.Cmd, t.Root)
-						}
-					}
+	// 任務或分組變化時按模型重建整個列表
+	rebuildList = func() {
+		taskListContainer.RemoveAll()
+		slots = slots[:0]
+		for _, g := range model.Groups() {
+			taskListContainer.Add(createGroupHeader(g))
+			slots = append(slots, listSlot{group: g})
+			if collapsed[g] {
+				continue
+			}
+			for _, t := range model.TasksIn(g) {
+				var row fyne.CanvasObject
+				switch t.Type {
+				case TaskSync:
+					row = createSyncRow(t)
+				case TaskGit:
+					row = createGitRow(t)
+				case TaskArchive:
+					row = createArchiveRow(t)
+				default:
+					row = createCmdRow(t)
 				}
+				taskListContainer.Add(container.NewBorder(nil, nil, newDragHandle(dropTask(t)), nil, row))
+				slots = append(slots, listSlot{group: g, task: t})
 			}
-			statusChan <- "✅ 全部組任務執行完畢"
+		}
+		taskListContainer.Refresh()
+	}
+	model.OnChanged(rebuildList)
 
-			// 3. 精準刷新並鎖死尺寸
-			time.Sleep(200 * time.Millisecond)
-			window.Content().Refresh()
-			window.Resize(currentSize)
-		}()
+	// --- 底部控制區 ---
+	forceCheck := widget.NewCheckWithData("強制覆蓋模式", model.ForceCopy)
+
+	syncBtn := widget.NewButtonWithIcon("🔥 按分組順序執行", theme.MediaPlayIcon(), func() {
+		c := model.Config()
+		runNow("全部分組", c, c.GroupOrder, nil)
 	})
+	stopBtn = widget.NewButtonWithIcon("停止", theme.MediaStopIcon(), func() {
+		if runs.Cancel() {
+			postStatus("正在取消...")
+		}
+	})
+	stopBtn.Disable()
+	clearQueueBtn = widget.NewButtonWithIcon("清空隊列", theme.ContentClearIcon(), func() {
+		runs.Clear()
+		postStatus("已清空隊列")
+	})
+	clearQueueBtn.Disable()
 
 	addBtnsRow := container.NewHBox(
 		widget.NewButtonWithIcon("加同步對", theme.ContentAddIcon(), func() {
-			taskListContainer.Add(createSyncRow(TaskItem{Type: TaskSync, GroupID: 1}))
-			taskListContainer.Refresh()
+			model.Add(TaskItem{Enabled: true, Type: TaskSync, GroupID: 1})
 		}),
 		widget.NewButtonWithIcon("加命令行", theme.ContentAddIcon(), func() {
-			taskListContainer.Add(createCmdRow(TaskItem{Type: TaskCmd, GroupID: 2}))
-			taskListContainer.Refresh()
+			model.Add(TaskItem{Enabled: true, Type: TaskCmd, GroupID: 2})
+		}),
+		widget.NewButtonWithIcon("加 Git 發布", theme.UploadIcon(), func() {
+			model.Add(TaskItem{Enabled: true, Type: TaskGit, GroupID: 2})
+		}),
+		widget.NewButtonWithIcon("加打包", theme.ContentAddIcon(), func() {
+			model.Add(TaskItem{Enabled: true, Type: TaskArchive, GroupID: 2})
+		}),
+		widget.NewButtonWithIcon("新建分組", theme.FolderNewIcon(), func() {
+			model.AddGroup()
+		}),
+		layout.NewSpacer(),
+		widget.NewButtonWithIcon("運行歷史", theme.ListIcon(), func() {
+			showHistoryWindow(myApp, rerun, func(id string) { openFile(historyLogPath(id)) })
+		}),
+		widget.NewButtonWithIcon("計劃任務", theme.HistoryIcon(), func() {
+			showSchedulesDialog(window, defaultProfile, model.Config().Schedules, func(s []Schedule) {
+				model.SetSchedules(s)
+				restartScheduler()
+			})
+		}),
+		widget.NewButtonWithIcon("密鑰管理", theme.AccountIcon(), func() {
+			showSecretsDialog(window)
 		}),
 	)
 
@@ -198,11 +594,11 @@ func main() {
 
 	bottomControls := container.NewVBox(
 		widget.NewSeparator(),
-		container.NewGridWithColumns(2,
-			container.NewBorder(nil, nil, widget.NewLabel("順序(如1,2):"), nil, orderEntry),
-			forceCheck,
-		),
-		container.NewPadded(syncBtn),
+		container.NewHBox(forceCheck, watchCheck, layout.NewSpacer(), nextLabel),
+		container.NewPadded(container.NewBorder(nil, nil, nil, stopBtn, syncBtn)),
+		container.NewBorder(nil, nil, nil, clearQueueBtn, queueLabel),
+		container.NewGridWithColumns(2, taskBar, overallBar),
+		progressLabel,
 		statusScroll, // 放入滾動容器
 	)
 
@@ -214,12 +610,72 @@ func main() {
 	)
 
 	window.SetOnClosed(func() {
-		saveConfig(Config{Tasks: collectAllTasks(taskListContainer), GroupOrder: orderEntry.Text, ForceCopy: forceCheck.Checked})
+		saveConfig(model.Config())
+	})
+
+	// --- 系統托盤 ---
+	// 菜單在主線程重建，默認配置直接讀模型
+	trayLoad := func(profile string) (Config, error) {
+		if profile == defaultProfile {
+			return model.Config(), nil
+		}
+		return loadProfile(profile)
+	}
+	tray = newSystemTray(myApp, window, trayLoad, trayActions{
+		Show: func() {
+			window.Show()
+			window.RequestFocus()
+		},
+		Run: func(profile string, group int) {
+			go func() {
+				c, err := profileConfig(profile)
+				if err != nil {
+					postStatus("讀取配置失敗: " + err.Error())
+					return
+				}
+				title, order := profile+" 全部分組", formatGroupOrder(groupOrderOf(c))
+				if group >= 0 {
+					title, order = fmt.Sprintf("%s 分組 %d", profile, group), fmt.Sprint(group)
+				}
+				runs.Submit(context.Background(), &runRequest{Title: title, Profile: profile, Config: c, Order: order})
+			}()
+		},
+		Stop: func() { runs.Cancel() },
+		OpenLog: func() {
+			path, err := latestHistoryLog()
+			if err != nil {
+				postStatus("無法打開日誌: " + err.Error())
+				return
+			}
+			openFile(path)
+		},
+		Quit: func() {
+			saveConfig(model.Config())
+			myApp.Quit()
+		},
 	})
+	if tray != nil {
+		// 關閉窗口只隱藏到托盤，從托盤菜單退出
+		window.SetCloseIntercept(window.Hide)
+		model.OnChanged(func() { tray.UpdateProfile(defaultProfile, model.Config()) })
+	}
 
+	// 狀態欄只顯示最新一條消息；逐個文件的事件只進日誌，進度事件驅動進度條
 	go func() {
-		for s := range statusChan {
-			statusLabel.SetText("狀態: " + s)
+		for e := range uiEvents {
+			switch e := e.(type) {
+			case Progress:
+				fyne.Do(func() {
+					progressTask = e.Task
+					taskBar.SetValue(e.TaskFraction())
+					overallBar.SetValue(e.Fraction())
+					progressLabel.SetText(e.String())
+				})
+			case FileCopied, FileDeleted:
+			default:
+				text := "狀態: " + redactSecrets(e.Text())
+				fyne.Do(func() { statusLabel.SetText(text) })
+			}
 		}
 	}()
 
@@ -227,81 +683,86 @@ func main() {
 	window.ShowAndRun()
 }
 
-// --- 其餘邏輯函數保持不變 ---
-func executeCommand(command, dir string) {
-	if command == "" {
-		return
-	}
-	args := strings.Fields(command)
-	cmd := exec.Command(args[0], args[1:]...)
-	cmd.Dir = dir
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: 0x08000000}
-	statusChan <- "運行中: " + command
-	_ = cmd.Run()
-}
-func collectAllTasks(c *fyne.Container) []TaskItem {
-	var tasks []TaskItem
-	for _, obj := range c.Objects {
-		padded, ok := obj.(*fyne.Container)
-		if !ok {
+// normalizeTaskNames 為未命名的任務補上「類型-序號」形式、不與其他任務重複的名稱。已有的名稱保持不變：
+// 重名由 duplicateTaskNames 報告給用戶修改，否則命令行和接口按名稱選擇時會找不到被改名的任務。
+// 序號取同類型中最小的空號而不是任務的位置，界面在加入和讀取時分配一次並寫回模型，拖動排序不會改名。
+func normalizeTaskNames(tasks []TaskItem) {
+	used := map[string]bool{}
+	for _, t := range tasks {
+		used[t.Name] = true
+	}
+	for i := range tasks {
+		if tasks[i].Name != "" {
 			continue
 		}
-		row := padded.Objects[0].(*fyne.Container)
-		header := row.Objects[0].(*fyne.Container)
-		gIDStr := header.Objects[1].(*widget.Entry).Text
-		var gID int
-		fmt.Sscanf(gIDStr, "%d", &gID)
-		typeLabel := header.Objects[2].(*widget.Label).Text
-		if strings.Contains(typeLabel, "同步") {
-			grid := row.Objects[1].(*fyne.Container)
-			src := grid.Objects[0].(*fyne.Container).Objects[0].(*widget.Entry).Text
-			dst := grid.Objects[1].(*fyne.Container).Objects[0].(*widget.Entry).Text
-			tasks = append(tasks, TaskItem{Type: TaskSync, GroupID: gID, Src: src, Dst: dst})
-		} else {
-			grid := row.Objects[1].(*fyne.Container)
-			tasks = append(tasks, TaskItem{Type: TaskCmd, GroupID: gID, Root: grid.Objects[0].(*widget.Entry).Text, Cmd: grid.Objects[1].(*widget.Entry).Text, Desc: grid.Objects[2].(*widget.Entry).Text})
+		base := strings.ToLower(string(tasks[i].Type))
+		name := base + "-1"
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s-%d", base, n)
 		}
+		used[name] = true
+		tasks[i].Name = name
 	}
-	return tasks
 }
-func fullSync(src, dst string, force bool) {
-	_ = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return nil
-		}
-		rel, _ := filepath.Rel(src, path)
-		target := filepath.Join(dst, rel)
-		if force {
-			os.MkdirAll(filepath.Dir(target), 0755)
+
+// duplicateTaskNames 返回被多個任務使用的名稱，按首次出現的順序。
+func duplicateTaskNames(tasks []TaskItem) []string {
+	count := map[string]int{}
+	var dup []string
+	for _, t := range tasks {
+		if count[t.Name]++; count[t.Name] == 2 {
+			dup = append(dup, t.Name)
 		}
-		statusChan <- "同步: " + rel
-		copyFile(path, target)
-		return nil
-	})
+	}
+	return dup
 }
-func copyFile(src, dst string) {
-	s, err := os.Open(src)
-	if err != nil {
-		return
+
+// --- 配置檔 ---
+// 默認配置沿用 configPath，其餘命名配置存放在 profiles/<名稱>.json。
+
+const (
+	defaultProfile = "default"
+	profilesDir    = "profiles"
+)
+
+func profilePath(name string) string {
+	if name == "" || name == defaultProfile {
+		return configPath
 	}
-	defer s.Close()
-	d, err := os.Create(dst)
+	return filepath.Join(profilesDir, name+".json")
+}
+
+func listProfiles() []string {
+	profiles := []string{defaultProfile}
+	matches, _ := filepath.Glob(filepath.Join(profilesDir, "*.json"))
+	for _, m := range matches {
+		profiles = append(profiles, strings.TrimSuffix(filepath.Base(m), ".json"))
+	}
+	return profiles
+}
+
+func loadProfile(name string) (Config, error) {
+	var c Config
+	data, err := os.ReadFile(profilePath(name))
 	if err != nil {
-		return
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%s: %w", profilePath(name), err)
 	}
-	defer d.Close()
-	_, _ = io.Copy(d, s)
+	normalizeTaskNames(c.Tasks)
+	return c, nil
 }
+
 func loadConfig() Config {
-	var c Config
-	data, err := os.ReadFile(configPath)
+	c, err := loadProfile(defaultProfile)
 	if err != nil {
 		return Config{GroupOrder: "1,2"}
 	}
-	_ = json.Unmarshal(data, &c)
 	return c
 }
 func saveConfig(c Config) {
+	normalizeTaskNames(c.Tasks)
 	data, _ := json.MarshalIndent(c, "", "  ")
 	_ = os.WriteFile(configPath, data, 0644)
 }
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2/data/binding"
+)
+
+// --- 任務數據模型 ---
+// 界面、保存和執行都從 taskModel 讀取：每一行的輸入框直接綁定到任務字段，
+// 不再從控件樹反推任務內容。任務始終按分組順序排列，列表順序即執行順序。
+
+type taskModel struct {
+	conf   *Config
+	tasks  binding.List[*TaskItem]
+	groups binding.List[int]
+	// List.Set 只在長度變化時通知監聽者，排序變化靠 rev 計數觸發重繪
+	rev binding.Int
+
+	ForceCopy binding.Bool
+}
+
+func newTaskModel(c Config) *taskModel {
+	m := &taskModel{
+		conf:   &c,
+		tasks:  binding.NewList(func(a, b *TaskItem) bool { return a == b }),
+		groups: binding.NewIntList(),
+		rev:    binding.NewInt(),
+	}
+	_ = m.groups.Set(groupOrderOf(c))
+	var items []*TaskItem
+	for i := range c.Tasks {
+		t := c.Tasks[i]
+		items = append(items, &t)
+	}
+	assignNames(items)
+	m.setTasks(items)
+	m.ForceCopy = binding.BindBool(&m.conf.ForceCopy)
+	return m
+}
+
+// groupOrderOf 解析配置中的組順序，未列出但有任務的組依次追加在後面。
+func groupOrderOf(c Config) []int {
+	var order []int
+	for _, s := range strings.Split(c.GroupOrder, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(s)); err == nil && !slices.Contains(order, id) {
+			order = append(order, id)
+		}
+	}
+	for _, t := range c.Tasks {
+		if !slices.Contains(order, t.GroupID) {
+			order = append(order, t.GroupID)
+		}
+	}
+	return order
+}
+
+// Items 返回模型中任務的指針，供界面行綁定。
+func (m *taskModel) Items() []*TaskItem {
+	items, _ := m.tasks.Get()
+	return items
+}
+
+func (m *taskModel) Groups() []int {
+	groups, _ := m.groups.Get()
+	return groups
+}
+
+// TasksIn 返回某一組內按執行順序排列的任務。
+func (m *taskModel) TasksIn(group int) []*TaskItem {
+	var out []*TaskItem
+	for _, t := range m.Items() {
+		if t.GroupID == group {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (m *taskModel) Add(t TaskItem) {
+	if !slices.Contains(m.Groups(), t.GroupID) {
+		_ = m.groups.Append(t.GroupID)
+	}
+	items := append(m.Items(), &t)
+	assignNames(items)
+	m.setTasks(items)
+}
+
+// assignNames 給未命名的任務分配名稱並寫回任務本身，之後排序變化不會再改變名稱。
+// 在列表通知界面、行控件綁定字段之前調用，輸入框顯示的就是分配的名稱。
+func assignNames(items []*TaskItem) {
+	tasks := make([]TaskItem, len(items))
+	for i, t := range items {
+		tasks[i] = *t
+	}
+	normalizeTaskNames(tasks)
+	for i, t := range items {
+		t.Name = tasks[i].Name
+	}
+}
+
+func (m *taskModel) Remove(t *TaskItem) {
+	_ = m.tasks.Remove(t)
+	m.touch()
+}
+
+// AddGroup 新建一個空分組並返回其 ID。
+func (m *taskModel) AddGroup() int {
+	id := 1
+	for _, g := range m.Groups() {
+		id = max(id, g+1)
+	}
+	_ = m.groups.Append(id)
+	m.touch()
+	return id
+}
+
+// RemoveGroup 只允許刪除空分組。
+func (m *taskModel) RemoveGroup(id int) {
+	if len(m.TasksIn(id)) == 0 {
+		_ = m.groups.Remove(id)
+		m.touch()
+	}
+}
+
+// MoveTask 把任務移到 group 組中 before 之前；before 為 nil 時放到組末尾。
+func (m *taskModel) MoveTask(t *TaskItem, group int, before *TaskItem) {
+	if t == before {
+		return
+	}
+	items := slices.DeleteFunc(slices.Clone(m.Items()), func(x *TaskItem) bool { return x == t })
+	t.GroupID = group
+	at := len(items)
+	if i := slices.Index(items, before); i >= 0 {
+		at = i
+	}
+	m.setTasks(slices.Insert(items, at, t))
+}
+
+// MoveGroup 把分組移到 before 組之前；before 不存在（如 -1）時移到最後。
+func (m *taskModel) MoveGroup(id, before int) {
+	if id == before {
+		return
+	}
+	groups := slices.DeleteFunc(slices.Clone(m.Groups()), func(g int) bool { return g == id })
+	at := len(groups)
+	if i := slices.Index(groups, before); i >= 0 {
+		at = i
+	}
+	_ = m.groups.Set(slices.Insert(groups, at, id))
+	m.setTasks(m.Items())
+}
+
+// setTasks 按分組順序穩定排序後寫回列表，組內保持原有先後。
+func (m *taskModel) setTasks(items []*TaskItem) {
+	groups := m.Groups()
+	slices.SortStableFunc(items, func(a, b *TaskItem) int {
+		return slices.Index(groups, a.GroupID) - slices.Index(groups, b.GroupID)
+	})
+	_ = m.tasks.Set(items)
+	m.touch()
+}
+
+func (m *taskModel) touch() {
+	v, _ := m.rev.Get()
+	_ = m.rev.Set(v + 1)
+}
+
+// OnChanged 在任務或分組的增刪、排序變化時回調，字段編輯不會觸發。
+func (m *taskModel) OnChanged(fn func()) {
+	m.rev.AddListener(binding.NewDataListener(fn))
+}
+
+// SetSchedules 替換配置中的計劃列表，隨配置一起保存。
+func (m *taskModel) SetSchedules(s []Schedule) {
+	m.conf.Schedules = s
+}
+
+// Config 返回當前模型的配置快照，可安全地交給後台執行。
+func (m *taskModel) Config() Config {
+	c := *m.conf
+	c.Tasks = nil
+	for _, t := range m.Items() {
+		c.Tasks = append(c.Tasks, *t)
+	}
+	normalizeTaskNames(c.Tasks)
+	c.GroupOrder = formatGroupOrder(m.Groups())
+	return c
+}
+
+func formatGroupOrder(groups []int) string {
+	var ids []string
+	for _, g := range groups {
+		ids = append(ids, fmt.Sprint(g))
+	}
+	return strings.Join(ids, ",")
+}
+
+// taskFields 是一個任務各字段的綁定，行控件直接讀寫任務本身。
+type taskFields struct {
+	Name    binding.String
+	Enabled binding.Bool
+	Src     binding.String
+	Dst     binding.String
+	Root    binding.String
+	Cmd     binding.String
+	Desc    binding.String
+	Mirror  binding.Bool
+	Include binding.String
+	Exclude binding.String
+	Branch  binding.String
+	Message binding.String
+	Archive binding.String
+	Keep    binding.String
+	Workers binding.String
+}
+
+func bindTask(t *TaskItem) taskFields {
+	return taskFields{
+		Name:    binding.BindString(&t.Name),
+		Enabled: binding.BindBool(&t.Enabled),
+		Src:     binding.BindString(&t.Src),
+		Dst:     binding.BindString(&t.Dst),
+		Root:    binding.BindString(&t.Root),
+		Cmd:     binding.BindString(&t.Cmd),
+		Desc:    binding.BindString(&t.Desc),
+		Mirror:  binding.BindBool(&t.Mirror),
+		Include: binding.BindString(&t.Include),
+		Exclude: binding.BindString(&t.Exclude),
+		Branch:  binding.BindString(&t.Branch),
+		Message: binding.BindString(&t.Message),
+		Archive: binding.BindString(&t.Archive),
+		Keep:    bindOptionalInt(&t.Keep),
+		Workers: bindOptionalInt(&t.Workers),
+	}
+}
+
+// bindOptionalInt 把可選的整數字段綁定為文本：0 顯示為空，輸入框能顯示佔位提示；無法解析的輸入不寫回。
+func bindOptionalInt(p *int) binding.String {
+	s := binding.NewString()
+	if *p != 0 {
+		s.Set(strconv.Itoa(*p))
+	}
+	s.AddListener(binding.NewDataListener(func() {
+		v, _ := s.Get()
+		if v = strings.TrimSpace(v); v == "" {
+			*p = 0
+		} else if n, err := strconv.Atoi(v); err == nil {
+			*p = n
+		}
+	}))
+	return s
+}
@@ -0,0 +1,36 @@
+package main
+
+import "fyne.io/fyne/v2"
+
+// --- 桌面通知 ---
+// 運行結束時發送系統通知，窗口被蓋住或隱藏到托盤時也能知道結果。
+// 默認成功、失敗和取消都通知，可按配置關閉其中一部分。
+
+type NotifyConfig struct {
+	Disabled      bool `json:"disabled,omitempty"`       // 完全不通知
+	SkipSuccess   bool `json:"skip_success,omitempty"`   // 成功時不通知
+	SkipCancelled bool `json:"skip_cancelled,omitempty"` // 取消時不通知
+}
+
+// runNotification 按配置為運行結果生成通知，不需要通知時返回 nil。
+func runNotification(n NotifyConfig, r runResult) *fyne.Notification {
+	if n.Disabled {
+		return nil
+	}
+	title := "Hugo Sync · " + r.Profile
+	switch r.Status() {
+	case "success":
+		if n.SkipSuccess {
+			return nil
+		}
+		title += " 完成"
+	case "cancelled":
+		if n.SkipCancelled {
+			return nil
+		}
+		title += " 已取消"
+	default:
+		title += " 失敗"
+	}
+	return fyne.NewNotification(title, redactSecrets(r.Summary()))
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// --- 運行進度 ---
+// 運行開始前預掃描各同步任務，估算要複製的文件數和字節數；
+// 每個任務開始時再按實際掃描結果修正。複製過程中按固定間隔彙報進度，
+// 不再逐個文件刷屏。
+
+const progressInterval = 250 * time.Millisecond
+
+type runProgress struct {
+	Task           string `json:"task"`
+	TaskFiles      int    `json:"task_files"`
+	TaskFilesTotal int    `json:"task_files_total"`
+	TaskBytes      int64  `json:"task_bytes"`
+	TaskBytesTotal int64  `json:"task_bytes_total"`
+
+	Files      int   `json:"files"`
+	FilesTotal int   `json:"files_total"`
+	Bytes      int64 `json:"bytes"`
+	BytesTotal int64 `json:"bytes_total"`
+
+	Rate float64       `json:"rate"` // 字節每秒
+	ETA  time.Duration `json:"-"`    // 0 表示未知
+}
+
+// MarshalJSON 把剩餘時間按毫秒輸出為 eta_ms，與報告中的 duration_ms 一致，未知時省略。
+func (p runProgress) MarshalJSON() ([]byte, error) {
+	type plain runProgress
+	return json.Marshal(struct {
+		plain
+		ETAMs int64 `json:"eta_ms,omitempty"`
+	}{plain(p), p.ETA.Milliseconds()})
+}
+
+// Fraction 返回整體完成比例，按字節計算，沒有字節時按文件數。
+func (p runProgress) Fraction() float64 {
+	return fraction(p.Files, p.FilesTotal, p.Bytes, p.BytesTotal)
+}
+
+// TaskFraction 返回當前任務的完成比例。
+func (p runProgress) TaskFraction() float64 {
+	return fraction(p.TaskFiles, p.TaskFilesTotal, p.TaskBytes, p.TaskBytesTotal)
+}
+
+func fraction(files, filesTotal int, bytes, bytesTotal int64) float64 {
+	switch {
+	case bytesTotal > 0:
+		return min(float64(bytes)/float64(bytesTotal), 1)
+	case filesTotal > 0:
+		return min(float64(files)/float64(filesTotal), 1)
+	}
+	return 0
+}
+
+// String 生成一行進度描述，如「12/40 文件 · 3.2/10.0 MB · 1.5 MB/s · 剩餘 5s」。
+func (p runProgress) String() string {
+	s := fmt.Sprintf("%d/%d 文件 · %s/%s", p.Files, p.FilesTotal, formatBytes(p.Bytes), formatBytes(p.BytesTotal))
+	if p.Rate > 0 {
+		s += fmt.Sprintf(" · %s/s", formatBytes(int64(p.Rate)))
+	}
+	if p.ETA > 0 {
+		s += " · 剩餘 " + p.ETA.Round(time.Second).String()
+	}
+	return s
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// progressTracker 累計一次運行的進度並限速發出 Progress 事件。nil 時所有方法都不做事。
+type progressTracker struct {
+	mu        sync.Mutex
+	p         runProgress
+	estimates map[string]taskEstimate // 預掃描得到的各任務估算
+	last      time.Time
+	lastBytes int64
+}
+
+type taskEstimate struct {
+	files int
+	bytes int64
+}
+
+func newProgressTracker(estimates map[string]taskEstimate) *progressTracker {
+	t := &progressTracker{estimates: estimates, last: time.Now()}
+	for _, e := range estimates {
+		t.p.FilesTotal += e.files
+		t.p.BytesTotal += e.bytes
+	}
+	return t
+}
+
+// StartTask 標記任務開始，並用實際掃描結果替換該任務的估算。
+func (t *progressTracker) StartTask(name string, files int, bytes int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	e := t.estimates[name]
+	delete(t.estimates, name)
+	t.p.FilesTotal += files - e.files
+	t.p.BytesTotal += bytes - e.bytes
+	t.p.Task = name
+	t.p.TaskFiles, t.p.TaskFilesTotal = 0, files
+	t.p.TaskBytes, t.p.TaskBytesTotal = 0, bytes
+	t.mu.Unlock()
+	t.emit(true)
+}
+
+// Add 記錄新完成的文件數和字節數。
+func (t *progressTracker) Add(files int, bytes int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.p.TaskFiles += files
+	t.p.Files += files
+	t.p.TaskBytes += bytes
+	t.p.Bytes += bytes
+	t.mu.Unlock()
+	t.emit(false)
+}
+
+// Finish 在運行結束時發出最後一次進度。
+func (t *progressTracker) Finish() {
+	if t != nil {
+		t.emit(true)
+	}
+}
+
+func (t *progressTracker) emit(force bool) {
+	t.mu.Lock()
+	now := time.Now()
+	if !force && now.Sub(t.last) < progressInterval {
+		t.mu.Unlock()
+		return
+	}
+	// 速率取平滑後的瞬時值，命令任務等不複製文件的時間不計入
+	if dt := now.Sub(t.last).Seconds(); dt > 0 && t.p.Bytes > t.lastBytes {
+		inst := float64(t.p.Bytes-t.lastBytes) / dt
+		if t.p.Rate == 0 {
+			t.p.Rate = inst
+		} else {
+			t.p.Rate = 0.7*t.p.Rate + 0.3*inst
+		}
+	}
+	t.last, t.lastBytes = now, t.p.Bytes
+	t.p.ETA = 0
+	if remaining := t.p.BytesTotal - t.p.Bytes; remaining > 0 && t.p.Rate > 0 {
+		t.p.ETA = time.Duration(float64(remaining) / t.p.Rate * float64(time.Second))
+	}
+	p := t.p
+	t.mu.Unlock()
+	publish(Progress{p})
+}
+
+// progressReader 把讀出的字節數實時計入進度，大文件傳輸過程中也能看到變化。
+// 傳輸失敗時用 Rollback 撤回已計入的字節，失敗或寫了一半的文件不算進進度。
+type progressReader struct {
+	r io.Reader
+	t *progressTracker
+	n int64 // 已計入進度的字節數
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.n += int64(n)
+	pr.t.Add(0, int64(n))
+	return n, err
+}
+
+// Rollback 從進度中撤回已計入的字節。
+func (pr *progressReader) Rollback() {
+	pr.t.Add(0, -pr.n)
+	pr.n = 0
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// --- 運行隊列 ---
+// 同一進程內同時只跑一次運行，其餘請求按順序排隊；
+// 與已在排隊的請求完全相同時合併，兩邊等到同一個結果。
+
+type runRequest struct {
+	Title   string
+	Profile string
+	Config  Config
+	Order   string
+	Names   []string
+	Started func() // 輪到該請求開始運行時回調，可為 nil
+	Merged  bool   // Submit 時與排隊中的相同請求合併，結果來自那次運行
+
+	// 運行自己的 ctx，與任何一個提交者無關，所有等待者都取消後才取消
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters []*runWaiter
+	live    int // 還沒取消的等待者數
+	started []func()
+}
+
+// runWaiter 是一個等待結果的提交者，字段由隊列的鎖保護。
+type runWaiter struct {
+	ch   chan runResult
+	stop func() bool // 停止監聽提交者的 ctx
+	done bool
+}
+
+func (w *runWaiter) finish(res runResult) {
+	if !w.done {
+		w.done = true
+		w.stop()
+		w.ch <- res
+	}
+}
+
+// same 判斷兩個請求是否會跑同樣的任務。配置內容不比較，合併時以後提交的為準。
+func (r *runRequest) same(o *runRequest) bool {
+	return r.Profile == o.Profile && r.Order == o.Order && slices.Equal(r.Names, o.Names) &&
+		r.Config.ForceCopy == o.Config.ForceCopy
+}
+
+// runExecFunc 真正執行一個請求並阻塞到結束。
+type runExecFunc func(ctx context.Context, r *runRequest) runResult
+
+type runQueue struct {
+	exec     runExecFunc
+	onChange func() // 隊列或當前運行變化時回調，不在鎖內調用
+
+	mu      sync.Mutex
+	looping bool // 已有 loop 在處理隊列
+	running *runRequest
+	pending []*runRequest
+}
+
+func newRunQueue(exec runExecFunc, onChange func()) *runQueue {
+	if onChange == nil {
+		onChange = func() {}
+	}
+	return &runQueue{exec: exec, onChange: onChange}
+}
+
+// Submit 提交一次運行，返回在運行結束時送出結果的通道。
+// 與排隊中的相同請求合併時改用本次的配置，編輯後再次提交不會跑舊配置。
+// ctx 取消時本次提交立即以取消結束；合併的請求只在所有提交者都取消後才真正取消。
+func (q *runQueue) Submit(ctx context.Context, r *runRequest) <-chan runResult {
+	w := &runWaiter{ch: make(chan runResult, 1)}
+	if ctx.Err() != nil {
+		w.ch <- runResult{Profile: r.Profile, Cancelled: true}
+		return w.ch
+	}
+	q.mu.Lock()
+	target, start := r, false
+	if i := slices.IndexFunc(q.pending, r.same); i >= 0 {
+		target = q.pending[i]
+		target.Config = r.Config
+		r.Merged = true
+	} else {
+		r.ctx, r.cancel = context.WithCancel(context.Background())
+		q.pending = append(q.pending, r)
+		start = !q.looping
+		q.looping = true
+	}
+	target.waiters = append(target.waiters, w)
+	target.live++
+	if r.Started != nil {
+		target.started = append(target.started, r.Started)
+	}
+	w.stop = context.AfterFunc(ctx, func() { q.leave(target, w) })
+	q.mu.Unlock()
+	switch {
+	case r.Merged:
+		postStatus("與排隊中的運行合併: " + r.Title)
+		return w.ch
+	case start:
+		go q.loop()
+	default:
+		postStatus("已加入隊列: " + r.Title)
+	}
+	q.onChange()
+	return w.ch
+}
+
+// leave 在提交者的 ctx 取消時調用。還有別的等待者時只讓這一個先以取消結束；
+// 最後一個離開時取消運行，排隊中的直接移出隊列，運行中的等引擎返回結果。
+func (q *runQueue) leave(r *runRequest, w *runWaiter) {
+	q.mu.Lock()
+	if w.done {
+		q.mu.Unlock()
+		return
+	}
+	if r.live--; r.live > 0 {
+		w.finish(runResult{Profile: r.Profile, Cancelled: true})
+		q.mu.Unlock()
+		return
+	}
+	r.cancel()
+	i := slices.Index(q.pending, r)
+	if i >= 0 {
+		q.pending = slices.Delete(q.pending, i, i+1)
+	}
+	q.mu.Unlock()
+	if i >= 0 {
+		q.deliver(r, runResult{Profile: r.Profile, Cancelled: true})
+		q.onChange()
+	}
+}
+
+// deliver 把結果交給還在等待的提交者，並釋放運行的 ctx。
+func (q *runQueue) deliver(r *runRequest, res runResult) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, w := range r.waiters {
+		w.finish(res)
+	}
+	r.cancel()
+}
+
+// loop 依次執行排隊的請求，直到隊列為空。
+func (q *runQueue) loop() {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.looping = false
+			q.mu.Unlock()
+			return
+		}
+		r := q.pending[0]
+		q.pending = q.pending[1:]
+		q.running = r
+		q.mu.Unlock()
+		q.onChange()
+
+		for _, fn := range r.started {
+			fn()
+		}
+		postStatus("開始: " + r.Title)
+		res := q.exec(r.ctx, r)
+		q.mu.Lock()
+		q.running = nil
+		q.mu.Unlock()
+		q.deliver(r, res)
+		q.onChange()
+	}
+}
+
+// Busy 報告當前是否有運行。
+func (q *runQueue) Busy() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.running != nil
+}
+
+// Cancel 取消當前運行，排隊的請求繼續。
+func (q *runQueue) Cancel() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.running == nil {
+		return false
+	}
+	q.running.cancel()
+	return true
+}
+
+// Clear 丟棄所有排隊的請求，它們以取消結束。
+func (q *runQueue) Clear() {
+	q.mu.Lock()
+	pending := q.pending
+	q.pending = nil
+	q.mu.Unlock()
+	for _, r := range pending {
+		q.deliver(r, runResult{Profile: r.Profile, Cancelled: true})
+	}
+	q.onChange()
+}
+
+// Describe 用一行文字描述當前運行和排隊情況，空閒時返回空串。
+func (q *runQueue) Describe() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var parts []string
+	if q.running != nil {
+		parts = append(parts, "運行中: "+q.running.Title)
+	}
+	if len(q.pending) > 0 {
+		var titles []string
+		for _, r := range q.pending {
+			titles = append(titles, r.Title)
+		}
+		parts = append(parts, "排隊: "+strings.Join(titles, "、"))
+	}
+	return strings.Join(parts, " ｜ ")
+}
+
+// Pending 返回排隊中的請求數。
+func (q *runQueue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
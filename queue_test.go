@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// blockingExec 記錄每次運行收到的配置，運行一直阻塞到 release 關閉或 ctx 取消。
+type blockingExec struct {
+	started chan *runRequest
+	release chan struct{}
+}
+
+func newBlockingExec() *blockingExec {
+	return &blockingExec{started: make(chan *runRequest, 10), release: make(chan struct{})}
+}
+
+func (b *blockingExec) exec(ctx context.Context, r *runRequest) runResult {
+	b.started <- r
+	select {
+	case <-b.release:
+		return runResult{Profile: r.Profile, Tasks: []taskResult{{Name: r.Config.Tasks[0].Desc}}}
+	case <-ctx.Done():
+		return runResult{Profile: r.Profile, Cancelled: true}
+	}
+}
+
+func queueRequest(title, desc string) *runRequest {
+	return &runRequest{Title: title, Profile: "p", Order: "1", Config: Config{Tasks: []TaskItem{{Desc: desc}}}}
+}
+
+func receive(t *testing.T, ch <-chan runResult) runResult {
+	t.Helper()
+	select {
+	case res := <-ch:
+		return res
+	case <-time.After(5 * time.Second):
+		t.Fatal("等不到結果")
+	}
+	return runResult{}
+}
+
+func ranConfig(res runResult) string {
+	if len(res.Tasks) == 0 {
+		return ""
+	}
+	return res.Tasks[0].Name
+}
+
+// 合併到排隊中的請求時用後提交的配置，兩邊拿到同一個結果。
+func TestRunQueueMergeUsesLatestConfig(t *testing.T) {
+	b := newBlockingExec()
+	q := newRunQueue(b.exec, nil)
+	first := q.Submit(context.Background(), queueRequest("當前", "running"))
+	<-b.started
+
+	old := q.Submit(context.Background(), queueRequest("舊", "v1"))
+	edited := queueRequest("改後", "v2")
+	again := q.Submit(context.Background(), edited)
+	if !edited.Merged || q.Pending() != 1 {
+		t.Fatalf("Merged = %v，排隊 %d 個，期望合併成 1 個", edited.Merged, q.Pending())
+	}
+	close(b.release)
+	receive(t, first)
+	if got := (<-b.started).Config.Tasks[0].Desc; got != "v2" {
+		t.Fatalf("合併後運行的配置是 %s，期望 v2", got)
+	}
+	for _, ch := range []<-chan runResult{old, again} {
+		if res := receive(t, ch); res.Cancelled || ranConfig(res) != "v2" {
+			t.Fatalf("結果 = %+v，期望 v2 的運行結果", res)
+		}
+	}
+}
+
+// 合併的提交者之一取消時只有它以取消結束，運行照常完成；全部取消時運行才取消。
+func TestRunQueueMergedCancel(t *testing.T) {
+	b := newBlockingExec()
+	q := newRunQueue(b.exec, nil)
+	first := q.Submit(context.Background(), queueRequest("當前", "running"))
+	<-b.started
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	ctxB, cancelB := context.WithCancel(context.Background())
+	a := q.Submit(ctxA, queueRequest("A", "v1"))
+	bb := q.Submit(ctxB, queueRequest("B", "v1"))
+	cancelA()
+	if res := receive(t, a); !res.Cancelled {
+		t.Fatalf("A 取消後結果 = %+v", res)
+	}
+	if q.Pending() != 1 {
+		t.Fatalf("還有提交者在等，請求不應移出隊列")
+	}
+
+	close(b.release)
+	receive(t, first)
+	<-b.started
+	if res := receive(t, bb); res.Cancelled || ranConfig(res) != "v1" {
+		t.Fatalf("B 的結果 = %+v，期望正常完成", res)
+	}
+	cancelB()
+
+	// 運行中最後一個提交者取消：運行被取消，結果來自引擎
+	b2 := newBlockingExec()
+	q2 := newRunQueue(b2.exec, nil)
+	ctxC, cancelC := context.WithCancel(context.Background())
+	c := q2.Submit(ctxC, queueRequest("C", "v1"))
+	<-b2.started
+	cancelC()
+	if res := receive(t, c); !res.Cancelled {
+		t.Fatalf("C 取消後結果 = %+v", res)
+	}
+}
+
+// 排隊中的請求在所有提交者取消後移出隊列，不會再運行。
+func TestRunQueueCancelPending(t *testing.T) {
+	b := newBlockingExec()
+	q := newRunQueue(b.exec, nil)
+	first := q.Submit(context.Background(), queueRequest("當前", "running"))
+	<-b.started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := q.Submit(ctx, queueRequest("排隊", "v1"))
+	cancel()
+	if res := receive(t, queued); !res.Cancelled {
+		t.Fatalf("結果 = %+v", res)
+	}
+	if q.Pending() != 0 {
+		t.Fatalf("取消後還有 %d 個排隊", q.Pending())
+	}
+	close(b.release)
+	receive(t, first)
+	select {
+	case r := <-b.started:
+		t.Fatalf("已取消的請求 %s 仍被運行", r.Title)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if done := q.Submit(ctx, queueRequest("已取消", "v1")); !receive(t, done).Cancelled {
+		t.Fatal("ctx 已取消時應直接以取消結束")
+	}
+}
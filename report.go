@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// --- 運行報告 ---
+// 每次運行在報告目錄寫一份 JSON（可選 JUnit XML），供無人值守時追查。
+
+const defaultReportDir = "reports"
+
+type runReport struct {
+	Profile    string       `json:"profile"`
+	Start      time.Time    `json:"start"`
+	End        time.Time    `json:"end"`
+	DurationMs int64        `json:"duration_ms"`
+	Status     string       `json:"status"` // success / failed / cancelled
+	Tasks      []taskReport `json:"tasks"`
+}
+
+type taskReport struct {
+	Name       string    `json:"name"`
+	Group      int       `json:"group"`
+	Type       TaskType  `json:"type"`
+	Status     string    `json:"status"`
+	Start      time.Time `json:"start"`
+	DurationMs int64     `json:"duration_ms"`
+	ExitCode   int       `json:"exit_code"`
+	Copied     int       `json:"copied"`
+	Skipped    int       `json:"skipped"`
+	Deleted    int       `json:"deleted"`
+	Bytes      int64     `json:"bytes"`
+	Error      string    `json:"error,omitempty"`
+	FileErrors []string  `json:"file_errors,omitempty"`
+}
+
+func (r runResult) Status() string {
+	switch {
+	case len(r.Failed()) > 0:
+		return "failed"
+	case r.Cancelled:
+		return "cancelled"
+	}
+	return "success"
+}
+
+func newRunReport(r runResult) runReport {
+	rep := runReport{
+		Profile:    r.Profile,
+		Start:      r.Start,
+		End:        r.Start.Add(r.Duration),
+		DurationMs: r.Duration.Milliseconds(),
+		Status:     r.Status(),
+	}
+	for _, t := range r.Tasks {
+		rep.Tasks = append(rep.Tasks, newTaskReport(t))
+	}
+	return rep
+}
+
+func newTaskReport(t taskResult) taskReport {
+	tr := taskReport{
+		Name:       t.Name,
+		Group:      t.Group,
+		Type:       t.Type,
+		Status:     "success",
+		Start:      t.Start,
+		DurationMs: t.Duration.Milliseconds(),
+		ExitCode:   t.ExitCode,
+		Copied:     t.Stats.Copied,
+		Skipped:    t.Stats.Skipped,
+		Deleted:    t.Stats.Deleted,
+		Bytes:      t.Stats.Bytes,
+	}
+	if t.Err != nil {
+		tr.Status = "failed"
+		if errors.Is(t.Err, context.Canceled) {
+			tr.Status = "cancelled"
+		}
+		tr.Error = redactSecrets(t.Err.Error())
+	}
+	for _, e := range t.Stats.Errors {
+		tr.FileErrors = append(tr.FileErrors, redactSecrets(e))
+	}
+	return tr
+}
+
+func reportDir(c Config) string {
+	if c.ReportDir != "" {
+		return c.ReportDir
+	}
+	return defaultReportDir
+}
+
+// writeReports 寫出本次運行的報告，返回 JSON 報告路徑。
+// 文件名取運行歷史的記錄 ID（精確到毫秒），同一秒內結束的運行不會互相覆蓋，也能與歷史對應。
+func writeReports(c Config, id string, r runResult) (string, error) {
+	dir := reportDir(c)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	base := filepath.Join(dir, id)
+	rep := newRunReport(r)
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(base+".json", data, 0644); err != nil {
+		return "", err
+	}
+	if c.JUnitReport {
+		if err := writeJUnit(base+".xml", rep); err != nil {
+			return base + ".json", err
+		}
+	}
+	return base + ".json", nil
+}
+
+// --- JUnit XML ---
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     float64     `xml:"time,attr"`
+	Stamp    string      `xml:"timestamp,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func writeJUnit(path string, rep runReport) error {
+	suite := junitSuite{
+		Name:  rep.Profile,
+		Tests: len(rep.Tasks),
+		Time:  float64(rep.DurationMs) / 1000,
+		Stamp: rep.Start.Format(time.RFC3339),
+	}
+	for _, t := range rep.Tasks {
+		c := junitCase{
+			Name:      t.Name,
+			Classname: fmt.Sprintf("%s.group%d", rep.Profile, t.Group),
+			Time:      float64(t.DurationMs) / 1000,
+		}
+		if t.Type.copiesFiles() {
+			c.SystemOut = fmt.Sprintf("copied=%d skipped=%d deleted=%d bytes=%d", t.Copied, t.Skipped, t.Deleted, t.Bytes)
+		}
+		switch t.Status {
+		case "cancelled":
+			c.Skipped = &struct{}{}
+			suite.Skipped++
+		case "failed":
+			c.Failure = &junitFailure{Message: t.Error, Body: fmt.Sprintf("exit code %d\n%s", t.ExitCode, strings.Join(t.FileErrors, "\n"))}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	data, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), data...), 0644)
+}
+
+// --- 讀取報告 ---
+
+// latestReport 返回報告目錄中最新的 JSON 報告路徑。
+func latestReport(dir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("%s 中沒有報告", dir)
+	}
+	// 文件名以時間開頭，字典序即時間序
+	sort.Strings(matches)
+	return matches[len(matches)-1], nil
+}
+
+func readReport(path string) (runReport, error) {
+	var rep runReport
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rep, err
+	}
+	return rep, json.Unmarshal(data, &rep)
+}
+
+func printReport(w io.Writer, rep runReport) {
+	fmt.Fprintf(w, "%s  %s  %s  耗時 %s\n", rep.Start.Format("2006-01-02 15:04:05"), rep.Profile, rep.Status, time.Duration(rep.DurationMs)*time.Millisecond)
+	for _, t := range rep.Tasks {
+		fmt.Fprintf(w, "  [%s] 分組 %d  %s  %s", t.Name, t.Group, t.Type, time.Duration(t.DurationMs)*time.Millisecond)
+		if t.Type.copiesFiles() {
+			fmt.Fprintf(w, "  複製 %d  跳過 %d  刪除 %d  %d 字節", t.Copied, t.Skipped, t.Deleted, t.Bytes)
+		} else {
+			fmt.Fprintf(w, "  退出碼 %d", t.ExitCode)
+		}
+		fmt.Fprintln(w)
+		if t.Error != "" {
+			fmt.Fprintln(w, "    錯誤:", t.Error)
+		}
+		for _, e := range t.FileErrors {
+			fmt.Fprintln(w, "    -", e)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// --- S3 協議 ---
+// 兼容 S3 的對象存儲（AWS、MinIO、R2、B2）的最小客戶端，用 AWS 簽名第 4 版簽名。
+// 請求體都先讀入內存以計算摘要，大文件由上層按分片上傳。
+
+const s3EmptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" // 空請求體的 SHA-256
+
+type s3Client struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	pathStyle bool // 路徑中帶桶名；否則桶名作為子域名
+	accessKey string
+	secretKey string
+	http      *http.Client
+}
+
+// s3Error 是服務器返回的錯誤響應。
+type s3Error struct {
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+	Key     string
+}
+
+func (e *s3Error) Error() string {
+	msg := e.Code
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return fmt.Sprintf("s3 %s: %d %s", e.Key, e.Status, msg)
+}
+
+func (e *s3Error) Is(target error) bool {
+	return target == fs.ErrNotExist && (e.Status == http.StatusNotFound || e.Code == "NoSuchKey")
+}
+
+// do 發出已簽名的請求；狀態碼不是 2xx 時返回 *s3Error。調用者負責關閉響應體。
+func (c *s3Client) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	host, p := c.endpoint.Host, "/"+key
+	if c.pathStyle {
+		p = strings.TrimSuffix("/"+c.bucket+p, "/")
+	} else {
+		host = c.bucket + "." + host
+	}
+	target := c.endpoint.Scheme + "://" + host + s3Escape(p, true)
+	if len(query) > 0 {
+		target += "?" + s3Query(query)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.Opaque = s3Escape(p, true) // 請求行原樣使用簽名時的路徑編碼
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.ContentLength = int64(len(body))
+	c.sign(req, body, time.Now().UTC())
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, readS3Error(resp, key)
+	}
+	return resp, nil
+}
+
+// call 發出請求並讀完響應體，out 非 nil 時按 XML 解碼。
+func (c *s3Client) call(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte, out any) (http.Header, error) {
+	resp, err := c.do(ctx, method, key, query, header, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// CompleteMultipartUpload 等操作可能在 200 響應中返回錯誤
+	if bytes.Contains(data[:min(len(data), 256)], []byte("<Error>")) {
+		e := &s3Error{Status: resp.StatusCode, Key: key}
+		xml.Unmarshal(data, e)
+		return nil, e
+	}
+	if out != nil {
+		if err := xml.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("s3 %s: 響應格式錯誤: %w", key, err)
+		}
+	}
+	return resp.Header, nil
+}
+
+func readS3Error(resp *http.Response, key string) error {
+	e := &s3Error{Status: resp.StatusCode, Key: key}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	xml.Unmarshal(data, e)
+	if e.Code == "" {
+		e.Code = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
+
+// sign 按 AWS 簽名第 4 版為請求加上 Authorization 頭。
+func (c *s3Client) sign(req *http.Request, body []byte, now time.Time) {
+	payload := s3EmptyHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payload = hex.EncodeToString(sum[:])
+	}
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.Opaque,
+		req.URL.RawQuery,
+		canonHeaders.String(),
+		signed,
+		payload,
+	}, "\n")
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signed, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape 按簽名要求做 URI 編碼：只保留非保留字符，keepSlash 時保留 /。
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Query 生成按鍵排序的規範查詢串，同時用作請求的查詢串。
+func s3Query(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3ETag 計算按本工具的上傳方式得到的 ETag：小文件是內容的 MD5，
+// 分片上傳的是各分片 MD5 拼接後再取 MD5，並附上「-分片數」。
+func s3ETag(r io.Reader, size int64) (string, error) {
+	if size <= s3PartSize {
+		h := md5.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	var sums []byte
+	parts := 0
+	for {
+		h := md5.New()
+		n, err := io.CopyN(h, r, s3PartSize)
+		if n > 0 {
+			sums = h.Sum(sums)
+			parts++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	sum := md5.Sum(sums)
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), parts), nil
+}
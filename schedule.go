@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// --- 計劃任務 ---
+// 每個配置可以有多條 cron 計劃，在界面或 daemon 運行期間按時觸發。
+// 每次觸發（包括錯過的）都記入計劃歷史，下次啟動時據此判斷是否錯過了運行。
+
+type Schedule struct {
+	Name     string `json:"name"`
+	Cron     string `json:"cron"`
+	Groups   string `json:"groups,omitempty"` // 留空則按配置的分組順序執行
+	Tasks    string `json:"tasks,omitempty"`  // 逗號分隔的任務名
+	Missed   string `json:"missed,omitempty"` // skip：錯過就跳過（默認）；run：啟動後補跑一次
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+const scheduleMissedRun = "run"
+
+// taskNames 解析計劃中點名的任務。
+func (s Schedule) taskNames() []string {
+	var names []string
+	for _, n := range strings.Split(s.Tasks, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// order 返回計劃要執行的分組順序。
+func (s Schedule) order(c Config) string {
+	if s.Groups != "" {
+		return s.Groups
+	}
+	return formatGroupOrder(groupOrderOf(c))
+}
+
+// --- 計劃歷史 ---
+
+const (
+	scheduleHistoryPath  = "schedule_history.json"
+	scheduleHistoryLimit = 500
+)
+
+type scheduleRecord struct {
+	Profile  string    `json:"profile"`
+	Schedule string    `json:"schedule"`
+	Planned  time.Time `json:"planned"`
+	Start    time.Time `json:"start,omitzero"`
+	End      time.Time `json:"end,omitzero"`
+	Status   string    `json:"status"` // success / failed / cancelled / missed
+	Catchup  bool      `json:"catchup,omitempty"`
+}
+
+var scheduleHistoryMu sync.Mutex
+
+func loadScheduleHistory() []scheduleRecord {
+	scheduleHistoryMu.Lock()
+	defer scheduleHistoryMu.Unlock()
+	return readScheduleHistory()
+}
+
+func readScheduleHistory() []scheduleRecord {
+	var records []scheduleRecord
+	data, err := os.ReadFile(scheduleHistoryPath)
+	if err == nil {
+		_ = json.Unmarshal(data, &records)
+	}
+	return records
+}
+
+func appendScheduleHistory(r scheduleRecord) {
+	scheduleHistoryMu.Lock()
+	defer scheduleHistoryMu.Unlock()
+	records := append(readScheduleHistory(), r)
+	if len(records) > scheduleHistoryLimit {
+		records = records[len(records)-scheduleHistoryLimit:]
+	}
+	data, _ := json.MarshalIndent(records, "", "  ")
+	_ = os.WriteFile(scheduleHistoryPath, data, 0644)
+}
+
+// lastPlanned 返回某條計劃最近一次應觸發的時間。
+func lastPlanned(records []scheduleRecord, profile, name string) time.Time {
+	var last time.Time
+	for _, r := range records {
+		if r.Profile == profile && r.Schedule == name && r.Planned.After(last) {
+			last = r.Planned
+		}
+	}
+	return last
+}
+
+// --- 調度循環 ---
+
+// scheduleRunFunc 執行計劃觸發的一次運行並阻塞到結束。
+type scheduleRunFunc func(ctx context.Context, s Schedule) runResult
+
+type scheduleEntry struct {
+	s    Schedule
+	expr cronExpr
+	next time.Time
+}
+
+// runScheduler 按 c 中的計劃觸發運行直到 ctx 取消。
+// 同一時間只跑一個計劃，運行期間到點的其他計劃按各自的錯過策略處理。
+// onNext 在每次確定下一次觸發時回調，沒有可用計劃時時間為零值。
+func runScheduler(ctx context.Context, profile string, c Config, run scheduleRunFunc, onNext func(name string, at time.Time)) {
+	var entries []*scheduleEntry
+	for _, s := range c.Schedules {
+		if s.Disabled {
+			continue
+		}
+		expr, err := parseCron(s.Cron)
+		if err != nil {
+			postStatus("計劃 " + s.Name + " 無效: " + err.Error())
+			continue
+		}
+		entries = append(entries, &scheduleEntry{s: s, expr: expr})
+	}
+
+	fire := func(e *scheduleEntry, planned time.Time, catchup bool) {
+		postStatus("計劃觸發: " + e.s.Name)
+		rec := scheduleRecord{Profile: profile, Schedule: e.s.Name, Planned: planned, Start: time.Now(), Catchup: catchup}
+		res := run(ctx, e.s)
+		rec.End, rec.Status = time.Now(), res.Status()
+		appendScheduleHistory(rec)
+	}
+	// catchUp 處理 since 之後、now 之前錯過的觸發：按策略補跑一次或記為錯過
+	catchUp := func(e *scheduleEntry, since, now time.Time) {
+		missed := e.expr.Next(since)
+		if missed.IsZero() || missed.After(now) {
+			return
+		}
+		if e.s.Missed == scheduleMissedRun {
+			fire(e, missed, true)
+			return
+		}
+		appendScheduleHistory(scheduleRecord{Profile: profile, Schedule: e.s.Name, Planned: missed, Status: "missed"})
+	}
+
+	history := loadScheduleHistory()
+	now := time.Now()
+	for _, e := range entries {
+		if last := lastPlanned(history, profile, e.s.Name); !last.IsZero() {
+			catchUp(e, last, now)
+		}
+		e.next = e.expr.Next(time.Now())
+	}
+
+	for ctx.Err() == nil {
+		var due *scheduleEntry
+		for _, e := range entries {
+			if !e.next.IsZero() && (due == nil || e.next.Before(due.next)) {
+				due = e
+			}
+		}
+		if due == nil {
+			onNext("", time.Time{})
+			<-ctx.Done()
+			return
+		}
+		onNext(due.s.Name, due.next)
+
+		// 最多等一分鐘就重新檢查，避免休眠或調整系統時鐘後錯過
+		wait := min(time.Until(due.next), time.Minute)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if time.Now().Before(due.next) {
+			continue
+		}
+
+		planned := due.next
+		fire(due, planned, false)
+		now := time.Now()
+		due.next = due.expr.Next(now)
+		for _, e := range entries {
+			if e != due && !e.next.IsZero() && !e.next.After(now) {
+				catchUp(e, e.next.Add(-time.Minute), now)
+				e.next = e.expr.Next(now)
+			}
+		}
+	}
+}
+
+// --- 計劃任務界面 ---
+
+// showSchedulesDialog 列出計劃及其下次觸發時間和最近的觸發歷史，
+// 增刪計劃後通過 onChange 交回新的列表。
+func showSchedulesDialog(window fyne.Window, profile string, schedules []Schedule, onChange func([]Schedule)) {
+	schedules = slices.Clone(schedules)
+	selected := -1
+	list := widget.NewList(
+		func() int { return len(schedules) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			s := schedules[i]
+			next := "無效"
+			if expr, err := parseCron(s.Cron); err == nil {
+				next = expr.Next(time.Now()).Format("2006-01-02 15:04")
+			}
+			if s.Disabled {
+				next = "已停用"
+			}
+			o.(*widget.Label).SetText(fmt.Sprintf("%s  [%s]  下次: %s", s.Name, s.Cron, next))
+		},
+	)
+	list.OnSelected = func(i widget.ListItemID) { selected = i }
+
+	var history []string
+	records := loadScheduleHistory()
+	for i := len(records) - 1; i >= 0 && len(history) < 50; i-- {
+		r := records[i]
+		if r.Profile != profile {
+			continue
+		}
+		line := fmt.Sprintf("%s  %s  %s", r.Planned.Format("01-02 15:04"), r.Schedule, r.Status)
+		if r.Catchup {
+			line += "（補跑）"
+		}
+		history = append(history, line)
+	}
+	historyList := widget.NewList(
+		func() int { return len(history) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) { o.(*widget.Label).SetText(history[i]) },
+	)
+
+	changed := func() {
+		selected = -1
+		list.UnselectAll()
+		list.Refresh()
+		onChange(slices.Clone(schedules))
+	}
+	addSchedule := func() {
+		nameEntry := widget.NewEntry()
+		cronEntry := widget.NewEntry()
+		cronEntry.SetPlaceHolder("如 0 3 * * * 或 @hourly")
+		groupsEntry := widget.NewEntry()
+		groupsEntry.SetPlaceHolder("留空為全部分組")
+		tasksEntry := widget.NewEntry()
+		tasksEntry.SetPlaceHolder("留空為分組內全部任務")
+		missedSelect := widget.NewSelect([]string{"skip", scheduleMissedRun}, nil)
+		missedSelect.SetSelected("skip")
+		dialog.ShowForm("新增計劃", "保存", "取消", []*widget.FormItem{
+			widget.NewFormItem("名稱", nameEntry),
+			widget.NewFormItem("cron", cronEntry),
+			widget.NewFormItem("分組", groupsEntry),
+			widget.NewFormItem("任務", tasksEntry),
+			widget.NewFormItem("錯過時", missedSelect),
+		}, func(ok bool) {
+			if !ok {
+				return
+			}
+			if _, err := parseCron(cronEntry.Text); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			name := strings.TrimSpace(nameEntry.Text)
+			if name == "" {
+				name = fmt.Sprintf("schedule-%d", len(schedules)+1)
+			}
+			schedules = append(schedules, Schedule{
+				Name: name, Cron: strings.TrimSpace(cronEntry.Text),
+				Groups: strings.TrimSpace(groupsEntry.Text), Tasks: strings.TrimSpace(tasksEntry.Text),
+				Missed: missedSelect.Selected,
+			})
+			changed()
+		}, window)
+	}
+
+	buttons := container.NewHBox(
+		widget.NewButtonWithIcon("新增", theme.ContentAddIcon(), addSchedule),
+		widget.NewButtonWithIcon("停用/啟用", theme.MediaPauseIcon(), func() {
+			if selected >= 0 {
+				schedules[selected].Disabled = !schedules[selected].Disabled
+				changed()
+			}
+		}),
+		widget.NewButtonWithIcon("刪除", theme.DeleteIcon(), func() {
+			if selected >= 0 {
+				schedules = slices.Delete(schedules, selected, selected+1)
+				changed()
+			}
+		}),
+	)
+	listScroll := container.NewVScroll(list)
+	listScroll.SetMinSize(fyne.NewSize(520, 160))
+	historyScroll := container.NewVScroll(historyList)
+	historyScroll.SetMinSize(fyne.NewSize(520, 160))
+	dialog.ShowCustom("計劃任務", "關閉", container.NewVBox(
+		listScroll, buttons,
+		widget.NewLabelWithStyle("最近觸發", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		historyScroll,
+	), window)
+}
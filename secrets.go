@@ -0,0 +1,368 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// --- 密鑰庫 ---
+// 任務中以 ${secret:名稱} 引用密鑰，只在執行前展開，配置文件裡永遠只保存佔位符。
+
+type secretStore interface {
+	Get(name string) (string, error)
+	Set(name, value string) error
+	Delete(name string) error
+	List() ([]string, error)
+}
+
+const (
+	secretsPath    = "sync_secrets.enc"
+	secretsPassEnv = "HUGO_SYNC_PASSPHRASE"
+	secretsTarget  = "hugo-sync:"
+)
+
+var (
+	errSecretsLocked  = errors.New("密鑰庫未解鎖")
+	errSecretNotFound = errors.New("密鑰不存在")
+
+	secretRef = regexp.MustCompile(`\$\{secret:([^}]+)\}`)
+
+	secrets = openSecretStore()
+
+	// 本次進程中展開過的密鑰值，輸出狀態前用來打碼
+	revealedMu sync.Mutex
+	revealed   = map[string]bool{}
+)
+
+// openSecretStore 優先使用系統鑰匙串，不可用時退回到口令加密的本地文件。
+func openSecretStore() secretStore {
+	if k := newKeyringStore(); k != nil {
+		return k
+	}
+	f := &fileSecretStore{path: secretsPath}
+	if pass := os.Getenv(secretsPassEnv); pass != "" {
+		_ = f.Unlock(pass)
+	}
+	return f
+}
+
+// expandSecrets 把字符串中的 ${secret:名稱} 替換為密鑰值。
+func expandSecrets(s string) (string, error) {
+	return replaceSecrets(s, nil)
+}
+
+// expandEndpoint 展開同步地址中的密鑰。scheme://... 形式的地址中密鑰值按 URI 轉義，
+// 含有 @ : / # ? % & 的口令不會破壞地址結構，後端解析地址後得到原值。
+func expandEndpoint(s string) (string, error) {
+	if !strings.Contains(s, "://") {
+		return expandSecrets(s)
+	}
+	return replaceSecrets(s, escapeURIComponent)
+}
+
+// escapeURIComponent 轉義非保留字符以外的所有字符。空格寫作 %20 而不是 +，
+// 在用戶信息、路徑和查詢參數中都能解碼回原值。
+func escapeURIComponent(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// replaceSecrets 替換密鑰引用，escape 非 nil 時對密鑰值轉義後再代入。
+// 原值和轉義後的值都記入 revealed，兩種形式都不會出現在輸出中。
+func replaceSecrets(s string, escape func(string) string) (string, error) {
+	var firstErr error
+	out := secretRef.ReplaceAllStringFunc(s, func(m string) string {
+		name := secretRef.FindStringSubmatch(m)[1]
+		v, err := secrets.Get(name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("密鑰 %s: %w", name, err)
+			}
+			return m
+		}
+		if v == "" {
+			return v
+		}
+		revealedMu.Lock()
+		revealed[v] = true
+		if escape != nil {
+			v = escape(v)
+			revealed[v] = true
+		}
+		revealedMu.Unlock()
+		return v
+	})
+	return out, firstErr
+}
+
+// expandTask 返回展開了密鑰的任務副本，原任務保持不變以便保存。
+func expandTask(t TaskItem) (TaskItem, error) {
+	var err error
+	for _, f := range []*string{&t.Src, &t.Dst} {
+		if *f, err = expandEndpoint(*f); err != nil {
+			return t, err
+		}
+	}
+	for _, f := range []*string{&t.Root, &t.Cmd} {
+		if *f, err = expandSecrets(*f); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// redactSecrets 把已展開過的密鑰值替換成星號，防止其出現在狀態欄或日誌中。
+func redactSecrets(s string) string {
+	revealedMu.Lock()
+	defer revealedMu.Unlock()
+	for v := range revealed {
+		s = strings.ReplaceAll(s, v, "******")
+	}
+	return s
+}
+
+// --- 口令加密文件 ---
+
+type fileSecretStore struct {
+	path string
+
+	mu     sync.Mutex
+	key    []byte
+	salt   []byte
+	values map[string]string
+}
+
+type secretsFile struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+const secretsKDFRounds = 600000
+
+func (f *fileSecretStore) Unlocked() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.key != nil
+}
+
+// Unlock 用口令解密密鑰文件；文件不存在時以該口令新建。
+func (f *fileSecretStore) Unlock(pass string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		f.salt = make([]byte, 16)
+		_, _ = rand.Read(f.salt)
+		f.key, err = pbkdf2.Key(sha256.New, pass, f.salt, secretsKDFRounds, 32)
+		f.values = map[string]string{}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	var sf secretsFile
+	if err := json.Unmarshal(data, &sf); err != nil {
+		return err
+	}
+	key, err := pbkdf2.Key(sha256.New, pass, sf.Salt, secretsKDFRounds, 32)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	plain, err := gcm.Open(nil, sf.Nonce, sf.Data, nil)
+	if err != nil {
+		return errors.New("口令錯誤")
+	}
+	values := map[string]string{}
+	if err := json.Unmarshal(plain, &values); err != nil {
+		return err
+	}
+	f.key, f.salt, f.values = key, sf.Salt, values
+	return nil
+}
+
+func (f *fileSecretStore) Get(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key == nil {
+		return "", errSecretsLocked
+	}
+	v, ok := f.values[name]
+	if !ok {
+		return "", errSecretNotFound
+	}
+	return v, nil
+}
+
+func (f *fileSecretStore) Set(name, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key == nil {
+		return errSecretsLocked
+	}
+	f.values[name] = value
+	return f.flush()
+}
+
+func (f *fileSecretStore) Delete(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key == nil {
+		return errSecretsLocked
+	}
+	delete(f.values, name)
+	return f.flush()
+}
+
+func (f *fileSecretStore) List() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key == nil {
+		return nil, errSecretsLocked
+	}
+	names := make([]string, 0, len(f.values))
+	for n := range f.values {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// flush 每次寫入都換新的 nonce 重新加密整個文件。
+func (f *fileSecretStore) flush() error {
+	plain, err := json.Marshal(f.values)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(f.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, _ = rand.Read(nonce)
+	data, err := json.MarshalIndent(secretsFile{Salt: f.salt, Nonce: nonce, Data: gcm.Seal(nil, nonce, plain, nil)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, data, 0600)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// --- 密鑰管理界面 ---
+
+func showSecretsDialog(window fyne.Window) {
+	if f, ok := secrets.(*fileSecretStore); ok && !f.Unlocked() {
+		passEntry := widget.NewPasswordEntry()
+		dialog.ShowForm("解鎖密鑰庫", "解鎖", "取消", []*widget.FormItem{
+			widget.NewFormItem("口令", passEntry),
+		}, func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := f.Unlock(passEntry.Text); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			showSecretsDialog(window)
+		}, window)
+		return
+	}
+
+	var names []string
+	selected := -1
+	list := widget.NewList(
+		func() int { return len(names) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText("${secret:" + names[i] + "}")
+		},
+	)
+	list.OnSelected = func(i widget.ListItemID) { selected = i }
+	reload := func() {
+		var err error
+		if names, err = secrets.List(); err != nil {
+			dialog.ShowError(err, window)
+		}
+		selected = -1
+		list.UnselectAll()
+		list.Refresh()
+	}
+	reload()
+
+	// 新增與輪換共用一個表單：名稱已存在時即覆蓋舊值
+	editSecret := func(name string) {
+		nameEntry := widget.NewEntry()
+		nameEntry.SetText(name)
+		valueEntry := widget.NewPasswordEntry()
+		dialog.ShowForm("設置密鑰", "保存", "取消", []*widget.FormItem{
+			widget.NewFormItem("名稱", nameEntry),
+			widget.NewFormItem("值", valueEntry),
+		}, func(ok bool) {
+			name := strings.TrimSpace(nameEntry.Text)
+			if !ok || name == "" {
+				return
+			}
+			if err := secrets.Set(name, valueEntry.Text); err != nil {
+				dialog.ShowError(err, window)
+			}
+			reload()
+		}, window)
+	}
+
+	buttons := container.NewHBox(
+		widget.NewButtonWithIcon("新增", theme.ContentAddIcon(), func() { editSecret("") }),
+		widget.NewButtonWithIcon("輪換", theme.ViewRefreshIcon(), func() {
+			if selected >= 0 {
+				editSecret(names[selected])
+			}
+		}),
+		widget.NewButtonWithIcon("刪除", theme.DeleteIcon(), func() {
+			if selected < 0 {
+				return
+			}
+			name := names[selected]
+			dialog.ShowConfirm("刪除密鑰", "確定刪除 "+name+"？", func(ok bool) {
+				if !ok {
+					return
+				}
+				if err := secrets.Delete(name); err != nil {
+					dialog.ShowError(err, window)
+				}
+				reload()
+			}, window)
+		}),
+	)
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(420, 240))
+	dialog.ShowCustom("密鑰管理", "關閉", container.NewBorder(
+		widget.NewLabel("在路徑或命令中以 ${secret:名稱} 引用，值不會寫入配置文件"), buttons, nil, nil, scroll,
+	), window)
+}
@@ -0,0 +1,6 @@
+//go:build !windows
+
+package main
+
+// 非 Windows 平台暫不接入系統鑰匙串，統一使用加密文件。
+func newKeyringStore() secretStore { return nil }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// --- Windows 憑據管理器 ---
+
+var (
+	advapi32         = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW    = advapi32.NewProc("CredReadW")
+	procCredWriteW   = advapi32.NewProc("CredWriteW")
+	procCredDeleteW  = advapi32.NewProc("CredDeleteW")
+	procCredEnumW    = advapi32.NewProc("CredEnumerateW")
+	procCredFree     = advapi32.NewProc("CredFree")
+	errCredNotFound  = syscall.Errno(1168) // ERROR_NOT_FOUND
+	credTypeGeneric  = uint32(1)
+	credPersistLocal = uint32(2)
+)
+
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+type keyringStore struct{}
+
+func newKeyringStore() secretStore {
+	if advapi32.Load() != nil || procCredReadW.Find() != nil {
+		return nil
+	}
+	return keyringStore{}
+}
+
+func (keyringStore) Get(name string) (string, error) {
+	target, _ := syscall.UTF16PtrFromString(secretsTarget + name)
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), uintptr(credTypeGeneric), 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(err, errCredNotFound) {
+			return "", errSecretNotFound
+		}
+		return "", err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func (keyringStore) Set(name, value string) error {
+	target, _ := syscall.UTF16PtrFromString(secretsTarget + name)
+	blob := []byte(value)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocal,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return err
+	}
+	return nil
+}
+
+func (keyringStore) Delete(name string) error {
+	target, _ := syscall.UTF16PtrFromString(secretsTarget + name)
+	if r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), uintptr(credTypeGeneric), 0); r == 0 && !errors.Is(err, errCredNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (keyringStore) List() ([]string, error) {
+	filter, _ := syscall.UTF16PtrFromString(secretsTarget + "*")
+	var count uint32
+	var creds **credential
+	r, _, err := procCredEnumW.Call(uintptr(unsafe.Pointer(filter)), 0, uintptr(unsafe.Pointer(&count)), uintptr(unsafe.Pointer(&creds)))
+	if r == 0 {
+		if errors.Is(err, errCredNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(creds)))
+	var names []string
+	for _, c := range unsafe.Slice(creds, count) {
+		names = append(names, strings.TrimPrefix(utf16PtrToString(c.TargetName), secretsTarget))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func utf16PtrToString(p *uint16) string {
+	n := 0
+	for ptr := unsafe.Pointer(p); *(*uint16)(ptr) != 0; n++ {
+		ptr = unsafe.Add(ptr, 2)
+	}
+	return syscall.UTF16ToString(unsafe.Slice(p, n))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+)
+
+// --- 系統托盤 ---
+// 支持托盤的平台上關閉窗口只是隱藏到托盤，工具可以整天在後台待命。
+// 托盤圖標反映運行狀態，菜單可以運行任意配置或分組、打開最近的日誌和退出。
+
+// trayActions 是托盤菜單觸發的界面操作。
+type trayActions struct {
+	Show    func()
+	Run     func(profile string, group int) // group 為 -1 時運行全部分組
+	Stop    func()
+	OpenLog func()
+	Quit    func()
+}
+
+type systemTray struct {
+	desk    desktop.App
+	actions trayActions
+	load    func(profile string) (Config, error)
+	status  *fyne.MenuItem
+	menu    *fyne.Menu
+
+	profiles []string         // 菜單中的配置，只在 Reload 時重新列出
+	groups   map[string][]int // 各配置的分組
+}
+
+// newSystemTray 在支持托盤的平台上創建托盤，否則返回 nil。所有方法都需在主線程調用。
+func newSystemTray(a fyne.App, window fyne.Window, load func(string) (Config, error), actions trayActions) *systemTray {
+	desk, ok := a.(desktop.App)
+	if !ok {
+		return nil
+	}
+	t := &systemTray{desk: desk, actions: actions, load: load}
+	desk.SetSystemTrayWindow(window)
+	t.SetState("空閒", false, false)
+	t.Reload()
+	return t
+}
+
+// Reload 重新列出配置並讀取各自的分組，然後重建菜單。
+func (t *systemTray) Reload() {
+	t.profiles = listProfiles()
+	t.groups = map[string][]int{}
+	for _, p := range t.profiles {
+		if c, err := t.load(p); err == nil {
+			t.groups[p] = groupOrderOf(c)
+		}
+	}
+	t.Rebuild()
+}
+
+// UpdateProfile 更新一個配置的分組，分組沒有變化時不重建菜單。編輯中的默認配置變化時調用。
+func (t *systemTray) UpdateProfile(profile string, c Config) {
+	groups := groupOrderOf(c)
+	if old, ok := t.groups[profile]; ok && slices.Equal(old, groups) {
+		return
+	}
+	t.groups[profile] = groups
+	t.Rebuild()
+}
+
+// Rebuild 按緩存的配置和分組重建菜單，不讀磁盤。
+func (t *systemTray) Rebuild() {
+	status := "空閒"
+	if t.status != nil {
+		status = t.status.Label
+	}
+	t.status = fyne.NewMenuItem(status, nil)
+	t.status.Disabled = true
+
+	var profiles []*fyne.MenuItem
+	for _, p := range t.profiles {
+		groups, ok := t.groups[p]
+		if !ok {
+			continue
+		}
+		items := []*fyne.MenuItem{
+			fyne.NewMenuItem("全部分組", func() { t.actions.Run(p, -1) }),
+			fyne.NewMenuItemSeparator(),
+		}
+		for _, g := range groups {
+			items = append(items, fyne.NewMenuItem(fmt.Sprintf("分組 %d", g), func() { t.actions.Run(p, g) }))
+		}
+		item := fyne.NewMenuItem(p, nil)
+		item.ChildMenu = fyne.NewMenu("", items...)
+		profiles = append(profiles, item)
+	}
+	runItem := fyne.NewMenuItem("運行", nil)
+	runItem.ChildMenu = fyne.NewMenu("", profiles...)
+
+	quit := fyne.NewMenuItem("退出", t.actions.Quit)
+	quit.IsQuit = true
+	t.menu = fyne.NewMenu("Hugo Sync",
+		t.status,
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("顯示窗口", t.actions.Show),
+		runItem,
+		fyne.NewMenuItem("停止當前運行", t.actions.Stop),
+		fyne.NewMenuItem("打開最近的日誌", t.actions.OpenLog),
+		fyne.NewMenuItem("重新讀取配置", t.Reload),
+		fyne.NewMenuItemSeparator(),
+		quit,
+	)
+	t.desk.SetSystemTrayMenu(t.menu)
+}
+
+// SetState 更新菜單頂部的狀態行和托盤圖標。
+func (t *systemTray) SetState(text string, running, failed bool) {
+	icon := theme.StorageIcon()
+	switch {
+	case running:
+		icon = theme.ViewRefreshIcon()
+	case failed:
+		icon = theme.ErrorIcon()
+	}
+	t.desk.SetSystemTrayIcon(icon)
+	if t.status != nil {
+		t.status.Label = text
+		t.menu.Refresh()
+	}
+}
+
+// fileURL 把本地路徑轉成可交給 OpenURL 的 file:// 地址。
+func fileURL(path string) (*url.URL, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	p := filepath.ToSlash(abs)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // Windows 盤符路徑
+	}
+	return &url.URL{Scheme: "file", Path: p}, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// --- 監視模式 ---
+// 遞歸監視各同步任務的源目錄，把編輯器保存時的一串事件合併成一次運行。
+
+type WatchConfig struct {
+	DebounceMs int    `json:"debounce_ms,omitempty"` // 合併事件的靜默時間，默認 1000
+	Scope      string `json:"scope,omitempty"`       // affected：只跑受影響的同步任務及同組中其後的任務（默認）；pipeline：跑整條流水線
+	Overlap    string `json:"overlap,omitempty"`     // queue：等當前運行結束再跑（默認）；restart：取消當前運行重新開始
+}
+
+const (
+	watchScopePipeline  = "pipeline"
+	watchOverlapRestart = "restart"
+)
+
+// watchRunFunc 執行一次運行並阻塞到結束；names 為 nil 表示整條流水線。
+type watchRunFunc func(ctx context.Context, names []string) runResult
+
+// watchRoot 是一個被監視的源目錄及其所屬任務。
+type watchRoot struct {
+	task   string
+	dir    string
+	filter taskFilter
+}
+
+func watchRoots(c Config) []watchRoot {
+	var roots []watchRoot
+	for _, t := range c.Tasks {
+		if t.Type != TaskSync || !t.Enabled {
+			continue
+		}
+		src, err := expandEndpoint(t.Src)
+		if err != nil {
+			continue
+		}
+		dir, ok := localPath(src) // 遠程源無法監視
+		if !ok || !isDir(dir) {
+			continue
+		}
+		roots = append(roots, watchRoot{task: t.Name, dir: filepath.Clean(dir), filter: newTaskFilter(t)})
+	}
+	return roots
+}
+
+// affectedTasks 返回包含 path 且未被過濾掉的任務名。
+func affectedTasks(roots []watchRoot, path string) []string {
+	var names []string
+	for _, r := range roots {
+		rel, err := filepath.Rel(r.dir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if rel != "." && r.filter.Excluded(rel) {
+			continue
+		}
+		if !slices.Contains(names, r.task) {
+			names = append(names, r.task)
+		}
+	}
+	return names
+}
+
+// withDownstream 給受影響的任務補上同組中排在其後的已啟用任務，
+// 同步源目錄後的構建命令和部署也要重跑。
+func withDownstream(tasks []TaskItem, names []string) []string {
+	out := slices.Clone(names)
+	for i, t := range tasks {
+		if !slices.Contains(names, t.Name) {
+			continue
+		}
+		for _, d := range tasks[i+1:] {
+			if d.GroupID == t.GroupID && d.Enabled && !slices.Contains(out, d.Name) {
+				out = append(out, d.Name)
+			}
+		}
+	}
+	return out
+}
+
+// addRecursive 把目錄及其所有未被排除的子目錄加入監視。
+func addRecursive(w *fsnotify.Watcher, root watchRoot, dir string) {
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if rel, _ := filepath.Rel(root.dir, path); rel != "." && root.filter.Excluded(rel) {
+			return filepath.SkipDir
+		}
+		_ = w.Add(path)
+		return nil
+	})
+}
+
+// watchAndRun 監視 c 中的源目錄直到 ctx 取消，防抖後調用 run。
+// 運行期間的新變化按 Overlap 策略排隊或重啟。
+func watchAndRun(ctx context.Context, c Config, run watchRunFunc) error {
+	roots := watchRoots(c)
+	if len(roots) == 0 {
+		return fmt.Errorf("沒有可監視的本地源目錄")
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	for _, r := range roots {
+		addRecursive(w, r, r.dir)
+	}
+	postStatus(fmt.Sprintf("監視中: %d 個源目錄", len(roots)))
+
+	debounce := time.Duration(c.Watch.DebounceMs) * time.Millisecond
+	if debounce <= 0 {
+		debounce = time.Second
+	}
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+
+	var (
+		changed   []string // 防抖窗口內受影響的任務
+		pending   []string // 等待當前運行結束後執行的任務
+		hasPend   bool
+		runCancel context.CancelFunc
+		runDone   chan struct{}
+	)
+	merge := func(dst, src []string) []string {
+		if c.Watch.Scope == watchScopePipeline {
+			return nil
+		}
+		for _, n := range src {
+			if !slices.Contains(dst, n) {
+				dst = append(dst, n)
+			}
+		}
+		return dst
+	}
+	start := func(names []string) {
+		var runCtx context.Context
+		runCtx, runCancel = context.WithCancel(ctx)
+		runDone = make(chan struct{})
+		done := runDone
+		go func() {
+			defer close(done)
+			run(runCtx, names)
+		}()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if runCancel != nil {
+				runCancel()
+				<-runDone
+			}
+			return nil
+
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Has(fsnotify.Chmod) && !ev.Has(fsnotify.Write) {
+				continue
+			}
+			names := affectedTasks(roots, ev.Name)
+			if len(names) == 0 {
+				continue
+			}
+			// 新建的子目錄也要監視
+			if ev.Has(fsnotify.Create) && isDir(ev.Name) {
+				for _, r := range roots {
+					if slices.Contains(names, r.task) {
+						addRecursive(w, r, ev.Name)
+					}
+				}
+			}
+			changed = merge(changed, withDownstream(c.Tasks, names))
+			timer.Reset(debounce)
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			postStatus("監視出錯: " + err.Error())
+
+		case <-timer.C:
+			names := changed
+			changed = nil
+			if runDone == nil {
+				start(names)
+				continue
+			}
+			pending, hasPend = merge(pending, names), true
+			if c.Watch.Overlap == watchOverlapRestart {
+				postStatus("源目錄又有變化，重新開始運行")
+				runCancel()
+			} else {
+				postStatus("源目錄又有變化，當前運行結束後再執行")
+			}
+
+		case <-runDone:
+			runCancel()
+			runCancel, runDone = nil, nil
+			if hasPend {
+				names := pending
+				pending, hasPend = nil, false
+				start(names)
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// --- WebDAV 協議 ---
+// 只實現同步需要的 PROPFIND、GET、PUT、MKCOL、DELETE、MOVE。
+// 認證在第一次收到 401 時按服務器的質詢選擇 Basic 或 Digest，之後的請求主動帶上。
+
+type davClient struct {
+	base *url.URL // 根目錄地址，路徑以 / 結尾
+	user string
+	pass string
+	http *http.Client
+
+	mu     sync.Mutex
+	basic  bool
+	digest *digestChallenge
+}
+
+// davError 是非 2xx 的響應。
+type davError struct {
+	Method string
+	Path   string
+	Status int
+}
+
+func (e *davError) Error() string {
+	return fmt.Sprintf("webdav %s %s: %d %s", e.Method, e.Path, e.Status, http.StatusText(e.Status))
+}
+
+func (e *davError) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return e.Status == http.StatusNotFound
+	case fs.ErrPermission:
+		return e.Status == http.StatusForbidden
+	}
+	return false
+}
+
+// url 返回相對路徑對應的地址，dir 為真時以 / 結尾。
+func (c *davClient) url(p string, dir bool) *url.URL {
+	u := c.base
+	if p != "" {
+		u = u.JoinPath(strings.Split(p, "/")...)
+	}
+	if dir && !strings.HasSuffix(u.Path, "/") {
+		u = u.JoinPath("/")
+	}
+	return u
+}
+
+// davStream 是只能讀一遍的請求體。發送時帶 Expect: 100-continue，
+// 服務器在讀取請求體之前就返回質詢（如 Digest 的 stale=true）時，沒讀過的請求體仍可重發，
+// 進度也不會重複計算。
+type davStream struct {
+	r    io.Reader
+	size int64 // 大於 0 時作為 Content-Length，否則分塊發送
+	read atomic.Bool
+}
+
+func (s *davStream) Read(p []byte) (int, error) {
+	s.read.Store(true)
+	return s.r.Read(p)
+}
+
+// do 發出請求並處理認證質詢。body 為 []byte 時可以在質詢後重發；
+// *davStream 只在還沒被讀取時重發，否則返回 401 錯誤。
+func (c *davClient) do(ctx context.Context, method string, u *url.URL, header http.Header, body any) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		var r io.Reader
+		stream, _ := body.(*davStream)
+		switch b := body.(type) {
+		case []byte:
+			r = bytes.NewReader(b)
+		case *davStream:
+			r = b
+		}
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if stream != nil {
+			req.Header.Set("Expect", "100-continue")
+			if stream.size > 0 {
+				req.ContentLength = stream.size
+			}
+		}
+		c.authorize(req)
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 && c.user != "" {
+			if c.challenge(resp.Header.Values("WWW-Authenticate")) && (stream == nil || !stream.read.Load()) {
+				resp.Body.Close()
+				continue
+			}
+		}
+		if resp.StatusCode/100 != 2 {
+			resp.Body.Close()
+			return nil, &davError{Method: method, Path: u.Path, Status: resp.StatusCode}
+		}
+		return resp, nil
+	}
+}
+
+// call 發出請求並讀完響應體。
+func (c *davClient) call(ctx context.Context, method string, u *url.URL, header http.Header, body any) ([]byte, error) {
+	resp, err := c.do(ctx, method, u, header, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// challenge 記下服務器的認證方式，優先 Digest；無法識別時返回 false。
+func (c *davClient) challenge(values []string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, v := range values {
+		scheme, params, _ := strings.Cut(v, " ")
+		if strings.EqualFold(scheme, "Digest") {
+			c.digest = parseDigestChallenge(params)
+			c.basic = false
+			return true
+		}
+	}
+	for _, v := range values {
+		if scheme, _, _ := strings.Cut(v, " "); strings.EqualFold(scheme, "Basic") {
+			c.basic = true
+			return true
+		}
+	}
+	return false
+}
+
+func (c *davClient) authorize(req *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.digest != nil:
+		req.Header.Set("Authorization", c.digest.authorization(c.user, c.pass, req.Method, req.URL.RequestURI()))
+	case c.basic:
+		req.SetBasicAuth(c.user, c.pass)
+	}
+}
+
+// --- Digest 認證 ---
+
+type digestChallenge struct {
+	realm, nonce, opaque, algorithm string
+	qop                             bool // 服務器支持 qop=auth
+	nc                              int
+}
+
+func parseDigestChallenge(s string) *digestChallenge {
+	d := &digestChallenge{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		var val string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			val, s = rest[1:end+1], rest[end+2:]
+		} else {
+			val, s, _ = strings.Cut(rest, ",")
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "realm":
+			d.realm = val
+		case "nonce":
+			d.nonce = val
+		case "opaque":
+			d.opaque = val
+		case "algorithm":
+			d.algorithm = strings.TrimSpace(val)
+		case "qop":
+			for _, q := range strings.Split(val, ",") {
+				if strings.TrimSpace(q) == "auth" {
+					d.qop = true
+				}
+			}
+		}
+	}
+	return d
+}
+
+// authorization 按 RFC 7616 計算請求的 Authorization 頭，調用者持有鎖。
+func (d *digestChallenge) authorization(user, pass, method, uri string) string {
+	var newHash func() hash.Hash = md5.New
+	alg := strings.ToUpper(d.algorithm)
+	if strings.HasPrefix(alg, "SHA-256") {
+		newHash = sha256.New
+	}
+	h := func(s string) string {
+		x := newHash()
+		io.WriteString(x, s)
+		return hex.EncodeToString(x.Sum(nil))
+	}
+	d.nc++
+	nc := fmt.Sprintf("%08x", d.nc)
+	cnonce := rand.Text()
+	ha1 := h(user + ":" + d.realm + ":" + pass)
+	if strings.HasSuffix(alg, "-SESS") {
+		ha1 = h(ha1 + ":" + d.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	var response string
+	if d.qop {
+		response = h(strings.Join([]string{ha1, d.nonce, nc, cnonce, "auth", ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + d.nonce + ":" + ha2)
+	}
+	v := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		user, d.realm, d.nonce, uri, response)
+	if d.algorithm != "" {
+		v += ", algorithm=" + d.algorithm
+	}
+	if d.qop {
+		v += fmt.Sprintf(`, qop=auth, nc=%s, cnonce="%s"`, nc, cnonce)
+	}
+	if d.opaque != "" {
+		v += fmt.Sprintf(`, opaque="%s"`, d.opaque)
+	}
+	return v
+}